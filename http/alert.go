@@ -0,0 +1,115 @@
+/*
+* Copyright 2015 Axibase Corporation or its affiliates. All Rights Reserved.
+*
+* Licensed under the Apache License, Version 2.0 (the "License").
+* You may not use this file except in compliance with the License.
+* A copy of the License is located at
+*
+* https://www.axibase.com/atsd/axibase-apache-2.0.pdf
+*
+* or in the "license" file accompanying this file. This file is distributed
+* on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+* express or implied. See the License for the specific language governing
+* permissions and limitations under the License.
+ */
+
+package http
+
+import (
+	"fmt"
+	"time"
+)
+
+// severities in ascending order
+var severities = []Severity{UNDEFINED, UNKNOWN, NORMAL, WARNING, MINOR, MAJOR, CRITICAL, FATAL}
+
+// SeverityRange returns the severities from min to max inclusive. An unknown bound or min
+// greater than max is an error, since an empty list would not filter alerts at all.
+func SeverityRange(min, max Severity) ([]Severity, error) {
+	from, to := severityIndex(min), severityIndex(max)
+	if from < 0 {
+		return nil, &ValueError{Field: "min", Value: min}
+	}
+	if to < 0 {
+		return nil, &ValueError{Field: "max", Value: max}
+	}
+	if from > to {
+		return nil, fmt.Errorf("http: severity range %v..%v is empty", min, max)
+	}
+	return append([]Severity{}, severities[from:to+1]...), nil
+}
+
+func severityIndex(severity Severity) int {
+	for i, s := range severities {
+		if s == severity {
+			return i
+		}
+	}
+	return -1
+}
+
+type Alert struct {
+	Id            uint64            `json:"id"`
+	Rule          string            `json:"rule"`
+	Entity        string            `json:"entity"`
+	Metric        string            `json:"metric"`
+	Tags          map[string]string `json:"tags"`
+	Severity      Severity          `json:"severity"`
+	Acknowledged  bool              `json:"acknowledged"`
+	RepeatCount   uint64            `json:"repeatCount"`
+	Message       string            `json:"message,omitempty"`
+	TextValue     string            `json:"textValue,omitempty"`
+	Value         float64           `json:"value"`
+	OpenValue     float64           `json:"openValue"`
+	OpenDate      time.Time         `json:"openDate"`
+	LastEventDate time.Time         `json:"lastEventDate"`
+}
+
+type AlertsQuery struct {
+	Metrics          []string   `json:"metrics,omitempty"`
+	Entity           string     `json:"entity,omitempty"`
+	Entities         []string   `json:"entities,omitempty"`
+	EntityGroup      string     `json:"entityGroup,omitempty"`
+	EntityExpression string     `json:"entityExpression,omitempty"`
+	Rules            []string   `json:"rules,omitempty"`
+	Severities       []Severity `json:"severities,omitempty"` //SeverityRange builds a list for a range of severities
+	MinSeverity      Severity   `json:"minSeverity,omitempty"`
+	Acknowledged     *bool      `json:"acknowledged,omitempty"`
+	StartDate        string     `json:"startDate,omitempty"`
+	EndDate          string     `json:"endDate,omitempty"`
+}
+
+type AlertUpdate struct {
+	Id           uint64 `json:"id"`
+	Acknowledged bool   `json:"acknowledged"`
+}
+
+type AlertHistory struct {
+	Alert          string            `json:"alert,omitempty"`
+	AlertDuration  uint64            `json:"alertDuration"` //milliseconds
+	AlertOpenDate  time.Time         `json:"alertOpenDate"`
+	Date           time.Time         `json:"date"`
+	ReceivedDate   time.Time         `json:"receivedDate"`
+	Entity         string            `json:"entity"`
+	Metric         string            `json:"metric"`
+	Rule           string            `json:"rule"`
+	RuleExpression string            `json:"ruleExpression,omitempty"`
+	RuleFilter     string            `json:"ruleFilter,omitempty"`
+	Tags           map[string]string `json:"tags"`
+	Severity       Severity          `json:"severity"`
+	Type           string            `json:"type"` //OPEN, REPEAT or CANCEL
+	RepeatCount    uint64            `json:"repeatCount"`
+	Value          float64           `json:"value"`
+	Window         uint64            `json:"window"`
+}
+
+type AlertHistoryQuery struct {
+	Metric           string `json:"metric,omitempty"`
+	Entity           string `json:"entity,omitempty"`
+	EntityGroup      string `json:"entityGroup,omitempty"`
+	EntityExpression string `json:"entityExpression,omitempty"`
+	Rule             string `json:"rule,omitempty"`
+	StartDate        string `json:"startDate,omitempty"`
+	EndDate          string `json:"endDate,omitempty"`
+	Limit            uint64 `json:"limit,omitempty"`
+}
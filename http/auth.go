@@ -0,0 +1,82 @@
+/*
+* Copyright 2015 Axibase Corporation or its affiliates. All Rights Reserved.
+*
+* Licensed under the Apache License, Version 2.0 (the "License").
+* You may not use this file except in compliance with the License.
+* A copy of the License is located at
+*
+* https://www.axibase.com/atsd/axibase-apache-2.0.pdf
+*
+* or in the "license" file accompanying this file. This file is distributed
+* on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+* express or implied. See the License for the specific language governing
+* permissions and limitations under the License.
+ */
+
+package http
+
+import (
+	"net/http"
+)
+
+// Authenticator adds credentials to every request sent by the Client
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+// AuthenticatorFunc adapts an ordinary function to the Authenticator interface
+type AuthenticatorFunc func(req *http.Request) error
+
+func (self AuthenticatorFunc) Authenticate(req *http.Request) error {
+	return self(req)
+}
+
+type basicAuth struct {
+	username string
+	password string
+}
+
+func BasicAuth(username, password string) Authenticator {
+	return &basicAuth{username: username, password: password}
+}
+
+func (self *basicAuth) Authenticate(req *http.Request) error {
+	req.SetBasicAuth(self.username, self.password)
+	return nil
+}
+
+// String hides the password so the authenticator is safe to log
+func (self *basicAuth) String() string {
+	return "BasicAuth(" + self.username + ", ****)"
+}
+func (self *basicAuth) GoString() string {
+	return self.String()
+}
+
+type tokenAuth struct {
+	scheme string
+	token  string
+}
+
+// BearerToken sends "Authorization: Bearer <token>" with every request
+func BearerToken(token string) Authenticator {
+	return &tokenAuth{scheme: "Bearer", token: token}
+}
+
+// ApiToken sends "Authorization: <scheme> <token>" with every request
+func ApiToken(scheme, token string) Authenticator {
+	return &tokenAuth{scheme: scheme, token: token}
+}
+
+func (self *tokenAuth) Authenticate(req *http.Request) error {
+	req.Header.Set("Authorization", self.scheme+" "+self.token)
+	return nil
+}
+
+// String hides the token so the authenticator is safe to log
+func (self *tokenAuth) String() string {
+	return self.scheme + " ****"
+}
+func (self *tokenAuth) GoString() string {
+	return self.String()
+}
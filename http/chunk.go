@@ -0,0 +1,156 @@
+/*
+* Copyright 2015 Axibase Corporation or its affiliates. All Rights Reserved.
+*
+* Licensed under the Apache License, Version 2.0 (the "License").
+* You may not use this file except in compliance with the License.
+* A copy of the License is located at
+*
+* https://www.axibase.com/atsd/axibase-apache-2.0.pdf
+*
+* or in the "license" file accompanying this file. This file is distributed
+* on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+* express or implied. See the License for the specific language governing
+* permissions and limitations under the License.
+ */
+
+package http
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ChunkConfig splits large series inserts and queries into several requests
+type ChunkConfig struct {
+	MaxSamples  int //maximum number of samples in one insert request, samples of a long series are split too, 0 is unlimited
+	MaxQueries  int //maximum number of queries in one query request, 0 is unlimited
+	Parallelism int //number of chunks sent at the same time, 1 by default
+}
+
+// ChunkError reports a chunk which failed, Series is set for inserts and Queries for queries
+type ChunkError struct {
+	Chunk   int
+	Series  []*Series
+	Queries []*SeriesQuery
+	Err     error
+}
+
+func (self *ChunkError) Error() string {
+	return fmt.Sprintf("chunk %v: %v", self.Chunk, self.Err)
+}
+func (self *ChunkError) Unwrap() error {
+	return self.Err
+}
+
+// ChunksError is returned when some chunks of a split request failed, the other chunks succeeded
+type ChunksError struct {
+	Chunks int
+	Errors []*ChunkError
+}
+
+func (self *ChunksError) Error() string {
+	messages := make([]string, len(self.Errors))
+	for i, chunkError := range self.Errors {
+		messages[i] = chunkError.Error()
+	}
+	return fmt.Sprintf("http: %v of %v chunks failed: %v", len(self.Errors), self.Chunks, strings.Join(messages, "; "))
+}
+func (self *ChunksError) Unwrap() []error {
+	errs := make([]error, len(self.Errors))
+	for i, chunkError := range self.Errors {
+		errs[i] = chunkError
+	}
+	return errs
+}
+
+// splitSeries divides series into chunks of at most maxSamples samples
+func splitSeries(series []*Series, maxSamples int) [][]*Series {
+	var chunks [][]*Series
+	var chunk []*Series
+	samples := 0
+	for _, s := range series {
+		if s == nil {
+			continue
+		}
+		if len(s.Data) == 0 {
+			chunk = append(chunk, s)
+			continue
+		}
+		for offset := 0; offset < len(s.Data); {
+			if samples == maxSamples {
+				chunks = append(chunks, chunk)
+				chunk, samples = nil, 0
+			}
+			n := len(s.Data) - offset
+			if n > maxSamples-samples {
+				n = maxSamples - samples
+			}
+			part := s
+			if n < len(s.Data) {
+				copied := *s
+				copied.Data = s.Data[offset : offset+n]
+				part = &copied
+			}
+			chunk = append(chunk, part)
+			samples += n
+			offset += n
+		}
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+func splitQueries(queries []*SeriesQuery, maxQueries int) [][]*SeriesQuery {
+	var chunks [][]*SeriesQuery
+	for len(queries) > maxQueries {
+		chunks = append(chunks, queries[:maxQueries])
+		queries = queries[maxQueries:]
+	}
+	if len(queries) > 0 {
+		chunks = append(chunks, queries)
+	}
+	return chunks
+}
+
+// runChunks calls send for every chunk index, at most parallelism at a time, and returns the error of each chunk.
+// Chunks which were not started before ctx is done fail with ctx.Err().
+func runChunks(ctx context.Context, chunks, parallelism int, send func(ctx context.Context, chunk int) error) []error {
+	errs := make([]error, chunks)
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	semaphore := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i := 0; i < chunks; i++ {
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			errs[i] = ctx.Err()
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			errs[i] = send(ctx, i)
+		}(i)
+	}
+	wg.Wait()
+	return errs
+}
+
+func countSamples(series []*Series) int {
+	samples := 0
+	for _, s := range series {
+		if s != nil {
+			samples += len(s.Data)
+		}
+	}
+	return samples
+}
@@ -17,13 +17,18 @@ package http
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
-	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/golang/glog"
 )
@@ -31,15 +36,23 @@ import (
 const (
 	seriesQueryPath  = "/api/v1/series"
 	seriesInsertPath = "/api/v1/series/insert"
+	seriesDeletePath = "/api/v1/series/delete"
 
 	messagesQueryPath  = "/api/v1/messages"
 	messagesInsertPath = "/api/v1/messages/insert"
 
+	propertiesQueryPath  = "/api/v1/properties/query"
 	propertiesInsertPath = "/api/v1/properties/insert"
+	propertiesDeletePath = "/api/v1/properties/delete"
 
 	entitiesPath      = "/api/v1/entities"
 	entitiesGroupPath = "/api/v1/entity-groups"
 
+	alertsQueryPath        = "/api/v1/alerts/query"
+	alertsUpdatePath       = "/api/v1/alerts/update"
+	alertsDeletePath       = "/api/v1/alerts/delete"
+	alertsHistoryQueryPath = "/api/v1/alerts/history/query"
+
 	metricsPath = "/api/v1/metrics"
 	commandPath = "/api/v1/command"
 
@@ -57,12 +70,51 @@ type Client struct {
 
 	Metric *metricApi
 
+	Commands *commandsApi
+
+	Alerts *alertsApi
+
 	SQL *sqlApi
 
-	httpClient *http.Client
+	httpClient    *http.Client
+	authenticator Authenticator
+	retryPolicy   *RetryPolicy
+	userAgent     string
+	headers       http.Header
+	spool         *Spool
+	gzipMinSize   int //minimum size of compressed payloads, -1 disables compression
+	chunking      ChunkConfig
 }
 
+// New creates a client for the ATSD instance at mUrl. Credentials embedded in mUrl
+// are removed from it and sent as basic authentication instead. The client has its own
+// transport, which ignores proxy environment variables; use NewWithOptions to configure it.
 func New(mUrl url.URL, insecureSkipVerify bool) *Client {
+	client, _ := NewWithOptions(mUrl, WithTransport(&http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: insecureSkipVerify},
+	}))
+	return client
+}
+
+// NewWithOptions creates a client for the ATSD instance at mUrl configured by opts.
+// Credentials embedded in mUrl are used unless an authenticator option is given.
+func NewWithOptions(mUrl url.URL, opts ...Option) (*Client, error) {
+	o := &options{}
+	if mUrl.User != nil {
+		password, _ := mUrl.User.Password()
+		o.authenticator = BasicAuth(mUrl.User.Username(), password)
+		mUrl.User = nil
+	}
+	for _, opt := range opts {
+		if err := opt(o); err != nil {
+			return nil, err
+		}
+	}
+	httpClient, err := o.buildHttpClient()
+	if err != nil {
+		return nil, err
+	}
+
 	var client = Client{url: &mUrl}
 	client.Series = &seriesApi{&client}
 	client.Properties = &propertiesApi{&client}
@@ -71,22 +123,140 @@ func New(mUrl url.URL, insecureSkipVerify bool) *Client {
 	client.Messages = &messagesApi{&client}
 	client.Metric = &metricApi{&client}
 	client.SQL = &sqlApi{&client}
-	client.httpClient = &http.Client{Transport: &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: insecureSkipVerify},
-	}}
-	return &client
+	client.Commands = &commandsApi{&client}
+	client.Alerts = &alertsApi{&client}
+	client.httpClient = httpClient
+	client.authenticator = o.authenticator
+	client.retryPolicy = o.retryPolicy
+	client.userAgent = o.userAgent
+	client.headers = o.headers
+	client.chunking = o.chunking
+	client.gzipMinSize = -1
+	if o.gzipMinSize != nil {
+		client.gzipMinSize = *o.gzipMinSize
+	}
+	return &client, nil
 }
 
 func (self *Client) Url() url.URL {
 	return *self.url
 }
-func (self *Client) request(reqType, apiUrl string, reqJson []byte) (string, error) {
-	req, err := http.NewRequest(reqType, self.url.String(), bytes.NewReader(reqJson))
-	req.URL.Opaque = req.URL.Path + apiUrl //todo: check
+
+// SetAuthenticator replaces the credentials sent with every request, nil disables authentication
+func (self *Client) SetAuthenticator(authenticator Authenticator) *Client {
+	self.authenticator = authenticator
+	return self
+}
+func (self *Client) SetBasicAuth(username, password string) *Client {
+	return self.SetAuthenticator(BasicAuth(username, password))
+}
+func (self *Client) SetBearerToken(token string) *Client {
+	return self.SetAuthenticator(BearerToken(token))
+}
+
+// SetSpool persists series, messages and properties inserts which fail because the server is unavailable
+// and replays them in order once it is back, nil disables spooling. Spooled inserts are reported as successful.
+func (self *Client) SetSpool(spool *Spool) *Client {
+	if self.spool != nil {
+		self.spool.Close()
+	}
+	self.spool = spool
+	if spool != nil {
+		spool.start(self)
+	}
+	return self
+}
+
+// SetChunking splits series inserts and queries exceeding the limits of config into several requests,
+// the zero ChunkConfig sends every batch in one request
+func (self *Client) SetChunking(config ChunkConfig) *Client {
+	self.chunking = config
+	return self
+}
+
+// SetRetryPolicy enables retries of failed requests, nil disables them
+func (self *Client) SetRetryPolicy(retryPolicy *RetryPolicy) *Client {
+	self.retryPolicy = retryPolicy
+	return self
+}
+
+// request sends the request bound to ctx, repeating it according to the retry policy.
+// If ctx is canceled or its deadline expires, ctx.Err() (context.Canceled or context.DeadlineExceeded) is returned.
+func (self *Client) request(ctx context.Context, reqType, apiUrl string, reqJson []byte) (string, error) {
+	var jsonData string
+	err := self.retry(ctx, reqType, apiUrl, func() error {
+		var err error
+		jsonData, err = self.do(ctx, reqType, apiUrl, reqJson)
+		return err
+	})
+	return jsonData, err
+}
+
+// insert posts the batch, spooling it if the server is unavailable. While batches are pending
+// in the spool new ones are appended to it, so the order of inserts is preserved.
+func (self *Client) insert(ctx context.Context, apiUrl string, reqJson []byte) error {
+	spool := self.spool
+	if spool != nil && spool.Pending() > 0 {
+		return spool.store(apiUrl, reqJson)
+	}
+	_, err := self.request(ctx, "POST", apiUrl, reqJson)
+	if err != nil && spool != nil && isUnavailable(err) {
+		if spoolErr := spool.store(apiUrl, reqJson); spoolErr != nil {
+			glog.Errorf("spool: could not store batch: %v", spoolErr)
+			return err
+		}
+		glog.Warningf("spool: stored batch for %v: %v", apiUrl, err)
+		return nil
+	}
+	return err
+}
+
+// stream sends the request like request does, but returns the response body unread.
+// The caller must close the body.
+func (self *Client) stream(ctx context.Context, reqType, apiUrl string, reqJson []byte) (io.ReadCloser, error) {
+	var res *http.Response
+	err := self.retry(ctx, reqType, apiUrl, func() error {
+		var err error
+		res, err = self.send(ctx, reqType, apiUrl, reqJson)
+		return err
+	})
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	res, err := self.httpClient.Do(req)
+	return res.Body, nil
+}
+
+// retry calls attempt until it succeeds or the retry policy gives up
+func (self *Client) retry(ctx context.Context, reqType, apiUrl string, attempt func() error) error {
+	policy := self.retryPolicy
+	if policy == nil || (isInsert(reqType, apiUrl) && !policy.RetryInserts) {
+		return attempt()
+	}
+	for n := 1; ; n++ {
+		err := attempt()
+		retry := err != nil && n < policy.MaxAttempts && ctx.Err() == nil && policy.shouldRetry(err)
+		var delay time.Duration
+		if retry {
+			delay = policy.backoff(n, err)
+		}
+		if policy.OnAttempt != nil {
+			policy.OnAttempt(RetryAttempt{Attempt: n, Method: reqType, Path: apiUrl, Err: err, Delay: delay})
+		}
+		if !retry {
+			return err
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (self *Client) do(ctx context.Context, reqType, apiUrl string, reqJson []byte) (string, error) {
+	res, err := self.send(ctx, reqType, apiUrl, reqJson)
 	if err != nil {
 		return "", err
 	}
@@ -94,6 +264,9 @@ func (self *Client) request(reqType, apiUrl string, reqJson []byte) (string, err
 
 	jsonData, err := ioutil.ReadAll(res.Body)
 	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
 		return "", err
 	}
 	var error struct {
@@ -103,27 +276,143 @@ func (self *Client) request(reqType, apiUrl string, reqJson []byte) (string, err
 	_ = json.Unmarshal(jsonData, &error)
 
 	if error.Error != "" {
-		return string(jsonData), errors.New(error.Error)
+		return string(jsonData), &APIError{
+			StatusCode: res.StatusCode,
+			Method:     reqType,
+			Path:       apiUrl,
+			Message:    error.Error,
+			Body:       string(jsonData),
+			Header:     res.Header,
+		}
 	}
 
 	return string(jsonData), nil
 }
 
+// send executes a single request. Responses with an error status are read and returned as *APIError,
+// otherwise the caller must close the response body. Gzip encoded responses are decoded transparently.
+func (self *Client) send(ctx context.Context, reqType, apiUrl string, reqJson []byte) (*http.Response, error) {
+	body, compressed := reqJson, false
+	if isInsert(reqType, apiUrl) {
+		var err error
+		body, compressed, err = compress(reqJson, self.gzipMinSize)
+		if err != nil {
+			return nil, &EncodeError{Err: err}
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, reqType, self.url.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, &RequestError{Method: reqType, Path: apiUrl, Err: err}
+	}
+	req.URL.Opaque = req.URL.Path + apiUrl //todo: check
+	for name, values := range self.headers {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	if compressed {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+	if self.userAgent != "" {
+		req.Header.Set("User-Agent", self.userAgent)
+	}
+	if self.authenticator != nil {
+		if err := self.authenticator.Authenticate(req); err != nil {
+			return nil, &RequestError{Method: reqType, Path: apiUrl, Err: err}
+		}
+	}
+	res, err := self.httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	if err := decompress(res); err != nil {
+		return nil, &DecodeError{Err: err}
+	}
+	if res.StatusCode < 400 {
+		return res, nil
+	}
+	defer res.Body.Close()
+
+	jsonData, err := ioutil.ReadAll(res.Body)
+	if err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	var error struct {
+		Error string `json:"error"`
+	}
+
+	_ = json.Unmarshal(jsonData, &error)
+
+	return nil, &APIError{
+		StatusCode: res.StatusCode,
+		Method:     reqType,
+		Path:       apiUrl,
+		Message:    error.Error,
+		Body:       string(jsonData),
+		Header:     res.Header,
+	}
+}
+
+// tagsParam joins the names of tags to include in the response, "*" requests all tags
+func tagsParam(tags []string) string {
+	if len(tags) == 1 && tags[0] == "*" {
+		return "*"
+	}
+	return strings.Join(tags, ",")
+}
+
 type seriesApi struct {
 	client *Client
 }
 
+// Query sends the queries, split into chunks if the client has a MaxQueries limit. Series
+// are returned in the order of the queries. If some chunks fail, series of the other chunks are
+// returned together with a *ChunksError.
 func (self *seriesApi) Query(queries []*SeriesQuery) ([]*Series, error) {
-
+	return self.QueryContext(context.Background(), queries)
+}
+func (self *seriesApi) QueryContext(ctx context.Context, queries []*SeriesQuery) ([]*Series, error) {
+	config := self.client.chunking
+	if config.MaxQueries <= 0 || len(queries) <= config.MaxQueries {
+		return self.query(ctx, queries)
+	}
+	chunks := splitQueries(queries, config.MaxQueries)
+	results := make([][]*Series, len(chunks))
+	errs := runChunks(ctx, len(chunks), config.Parallelism, func(ctx context.Context, i int) error {
+		var err error
+		results[i], err = self.query(ctx, chunks[i])
+		return err
+	})
+	var series []*Series
+	chunksError := &ChunksError{Chunks: len(chunks)}
+	for i, err := range errs {
+		if err != nil {
+			chunksError.Errors = append(chunksError.Errors, &ChunkError{Chunk: i, Queries: chunks[i], Err: err})
+			continue
+		}
+		series = append(series, results[i]...)
+	}
+	if len(chunksError.Errors) > 0 {
+		return series, chunksError
+	}
+	return series, nil
+}
+func (self *seriesApi) query(ctx context.Context, queries []*SeriesQuery) ([]*Series, error) {
 	request := struct {
 		Queries []*SeriesQuery `json:"queries"`
 	}{queries}
 
 	jsonRequest, err := json.Marshal(request)
 	if err != nil {
-		panic(err)
+		return nil, &EncodeError{Err: err}
 	}
-	jsonData, err := self.client.request("POST", seriesQueryPath, jsonRequest)
+	jsonData, err := self.client.request(ctx, "POST", seriesQueryPath, jsonRequest)
 	if err != nil {
 		return nil, err
 	}
@@ -132,7 +421,7 @@ func (self *seriesApi) Query(queries []*SeriesQuery) ([]*Series, error) {
 	}
 	err = json.Unmarshal([]byte(jsonData), &series)
 	if err != nil {
-		panic(err)
+		return nil, &DecodeError{Body: jsonData, Err: err}
 	}
 	for _, s := range series.Series {
 		if s.Warning != "" {
@@ -142,12 +431,37 @@ func (self *seriesApi) Query(queries []*SeriesQuery) ([]*Series, error) {
 	return series.Series, nil
 }
 
+// Insert inserts the series, split into chunks if the client has a MaxSamples limit.
+// If some chunks fail, a *ChunksError listing the series of the failed chunks is returned.
 func (self *seriesApi) Insert(series []*Series) error {
+	return self.InsertContext(context.Background(), series)
+}
+func (self *seriesApi) InsertContext(ctx context.Context, series []*Series) error {
+	config := self.client.chunking
+	if config.MaxSamples <= 0 || countSamples(series) <= config.MaxSamples {
+		return self.insert(ctx, series)
+	}
+	chunks := splitSeries(series, config.MaxSamples)
+	errs := runChunks(ctx, len(chunks), config.Parallelism, func(ctx context.Context, i int) error {
+		return self.insert(ctx, chunks[i])
+	})
+	chunksError := &ChunksError{Chunks: len(chunks)}
+	for i, err := range errs {
+		if err != nil {
+			chunksError.Errors = append(chunksError.Errors, &ChunkError{Chunk: i, Series: chunks[i], Err: err})
+		}
+	}
+	if len(chunksError.Errors) > 0 {
+		return chunksError
+	}
+	return nil
+}
+func (self *seriesApi) insert(ctx context.Context, series []*Series) error {
 	jsonSeries, err := json.Marshal(series)
 	if err != nil {
-		panic(err)
+		return &EncodeError{Err: err}
 	}
-	_, err = self.client.request("POST", seriesInsertPath, jsonSeries)
+	err = self.client.insert(ctx, seriesInsertPath, jsonSeries)
 	if err != nil {
 		return err
 	}
@@ -155,16 +469,53 @@ func (self *seriesApi) Insert(series []*Series) error {
 	return nil
 }
 
+// Delete removes samples of the series matching the entity, metric and tags.
+// Nil timeRange removes all samples of the matching series.
+func (self *seriesApi) Delete(entity, metric string, tags map[string]string, timeRange *TimeRange) error {
+	return self.DeleteContext(context.Background(), entity, metric, tags, timeRange)
+}
+func (self *seriesApi) DeleteContext(ctx context.Context, entity, metric string, tags map[string]string, timeRange *TimeRange) error {
+	request := []*seriesDeleteQuery{{Entity: entity, Metric: metric, Tags: tags}}
+	if timeRange != nil {
+		if !timeRange.Start.IsZero() {
+			request[0].StartDate = timeRange.Start.UTC().Format(time.RFC3339Nano)
+		}
+		if !timeRange.End.IsZero() {
+			request[0].EndDate = timeRange.End.UTC().Format(time.RFC3339Nano)
+		}
+	}
+	jsonRequest, err := json.Marshal(request)
+	if err != nil {
+		return &EncodeError{Err: err}
+	}
+	_, err = self.client.request(ctx, "POST", seriesDeletePath, jsonRequest)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// List returns the descriptors of the series of the metric, empty entity and nil tags match all series
+func (self *seriesApi) List(metric, entity string, tags map[string]string) ([]*SeriesDescriptor, error) {
+	return self.ListContext(context.Background(), metric, entity, tags)
+}
+func (self *seriesApi) ListContext(ctx context.Context, metric, entity string, tags map[string]string) ([]*SeriesDescriptor, error) {
+	return self.client.Metric.SeriesContext(ctx, metric, entity, tags)
+}
+
 type propertiesApi struct {
 	client *Client
 }
 
 func (self *propertiesApi) Insert(properties []*Property) error {
+	return self.InsertContext(context.Background(), properties)
+}
+func (self *propertiesApi) InsertContext(ctx context.Context, properties []*Property) error {
 	jsonProperties, err := json.Marshal(properties)
 	if err != nil {
-		panic(err)
+		return &EncodeError{Err: err}
 	}
-	_, err = self.client.request("POST", propertiesInsertPath, jsonProperties)
+	err = self.client.insert(ctx, propertiesInsertPath, jsonProperties)
 	if err != nil {
 		return err
 	}
@@ -172,17 +523,95 @@ func (self *propertiesApi) Insert(properties []*Property) error {
 	return nil
 }
 
+func (self *propertiesApi) Query(queries []*PropertiesQuery) ([]*Property, error) {
+	return self.QueryContext(context.Background(), queries)
+}
+func (self *propertiesApi) QueryContext(ctx context.Context, queries []*PropertiesQuery) ([]*Property, error) {
+	jsonRequest, err := json.Marshal(queries)
+	if err != nil {
+		return nil, &EncodeError{Err: err}
+	}
+	jsonData, err := self.client.request(ctx, "POST", propertiesQueryPath, jsonRequest)
+	if err != nil {
+		return nil, err
+	}
+	var properties []*Property
+	err = json.Unmarshal([]byte(jsonData), &properties)
+	if err != nil {
+		return nil, &DecodeError{Body: jsonData, Err: err}
+	}
+	return properties, nil
+}
+
+func (self *propertiesApi) Delete(queries []*PropertiesDeleteQuery) error {
+	return self.DeleteContext(context.Background(), queries)
+}
+func (self *propertiesApi) DeleteContext(ctx context.Context, queries []*PropertiesDeleteQuery) error {
+	jsonRequest, err := json.Marshal(queries)
+	if err != nil {
+		return &EncodeError{Err: err}
+	}
+	_, err = self.client.request(ctx, "POST", propertiesDeletePath, jsonRequest)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Types lists the types of properties stored for the entity
+func (self *propertiesApi) Types(entity string) ([]string, error) {
+	return self.TypesContext(context.Background(), entity)
+}
+func (self *propertiesApi) TypesContext(ctx context.Context, entity string) ([]string, error) {
+	return self.client.Entities.PropertyTypesContext(ctx, entity)
+}
+
+// Catalog returns all properties of the entity grouped by type, the properties of each type are ordered by key
+func (self *propertiesApi) Catalog(entity string) (map[string][]*Property, error) {
+	return self.CatalogContext(context.Background(), entity)
+}
+func (self *propertiesApi) CatalogContext(ctx context.Context, entity string) (map[string][]*Property, error) {
+	propTypes, err := self.TypesContext(ctx, entity)
+	if err != nil {
+		return nil, err
+	}
+	catalog := map[string][]*Property{}
+	if len(propTypes) == 0 {
+		return catalog, nil
+	}
+	queries := make([]*PropertiesQuery, 0, len(propTypes))
+	for _, propType := range propTypes {
+		queries = append(queries, &PropertiesQuery{Type: propType, Entity: entity})
+	}
+	properties, err := self.QueryContext(ctx, queries)
+	if err != nil {
+		return nil, err
+	}
+	for _, property := range properties {
+		catalog[property.PropType()] = append(catalog[property.PropType()], property)
+	}
+	for _, list := range catalog {
+		sort.SliceStable(list, func(i, j int) bool {
+			return keyString(list[i].Key()) < keyString(list[j].Key())
+		})
+	}
+	return catalog, nil
+}
+
 type entitiesApi struct {
 	client *Client
 }
 
 func (self *entitiesApi) Create(entity *Entity) error {
+	return self.CreateContext(context.Background(), entity)
+}
+func (self *entitiesApi) CreateContext(ctx context.Context, entity *Entity) error {
 	jsonRequest, err := json.Marshal(entity)
 	if err != nil {
-		panic(err)
+		return &EncodeError{Err: err}
 	}
 	path := entitiesPath + "/" + url.QueryEscape(entity.Name())
-	_, err = self.client.request("PUT", path, jsonRequest)
+	_, err = self.client.request(ctx, "PUT", path, jsonRequest)
 	if err != nil {
 		return err
 	}
@@ -190,38 +619,31 @@ func (self *entitiesApi) Create(entity *Entity) error {
 }
 
 func (self *entitiesApi) Update(entity *Entity) error {
+	return self.UpdateContext(context.Background(), entity)
+}
+func (self *entitiesApi) UpdateContext(ctx context.Context, entity *Entity) error {
 	jsonRequest, err := json.Marshal(entity)
 	if err != nil {
-		panic(err)
+		return &EncodeError{Err: err}
 	}
 	path := entitiesPath + "/" + url.QueryEscape(entity.Name())
-	_, err = self.client.request("PATCH", path, jsonRequest)
+	_, err = self.client.request(ctx, "PATCH", path, jsonRequest)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 func (self *entitiesApi) List(expression string, tags []string, limit uint64) ([]*Entity, error) {
-	tagsParams := ""
-	if len(tags) == 1 && tags[0] == "*" {
-		tagsParams = "*"
-	} else {
-		for i, tag := range tags {
-			if i == 0 {
-				tagsParams += tag
-			} else {
-				tagsParams += "," + tag
-			}
-		}
-	}
-
+	return self.ListContext(context.Background(), expression, tags, limit)
+}
+func (self *entitiesApi) ListContext(ctx context.Context, expression string, tags []string, limit uint64) ([]*Entity, error) {
 	path := entitiesPath
 	q := url.Values{}
-	q.Set("tags", tagsParams)
+	q.Set("tags", tagsParam(tags))
 	q.Set("expression", expression)
 	q.Set("limit", strconv.FormatUint(limit, 10))
 	path += "?" + q.Encode()
-	jsonData, err := self.client.request("GET", path, []byte{})
+	jsonData, err := self.client.request(ctx, "GET", path, []byte{})
 	if err != nil {
 		return nil, err
 	}
@@ -229,86 +651,298 @@ func (self *entitiesApi) List(expression string, tags []string, limit uint64) ([
 	var entities []*Entity
 	err = json.Unmarshal([]byte(jsonData), &entities)
 	if err != nil {
-		panic(err)
+		return nil, &DecodeError{Body: jsonData, Err: err}
 	}
 
 	return entities, nil
 }
 
+// Iterator pages through the entities matching expression, pageSize entities per request (1000 if 0)
+func (self *entitiesApi) Iterator(expression string, tags []string, pageSize uint64) *EntityIterator {
+	return self.IteratorContext(context.Background(), expression, tags, pageSize)
+}
+func (self *entitiesApi) IteratorContext(ctx context.Context, expression string, tags []string, pageSize uint64) *EntityIterator {
+	return newEntityIterator(ctx, expression, pageSize, func(ctx context.Context, expression string, limit uint64) ([]*Entity, error) {
+		return self.ListContext(ctx, expression, tags, limit)
+	})
+}
+
+func (self *entitiesApi) Get(name string) (*Entity, error) {
+	return self.GetContext(context.Background(), name)
+}
+func (self *entitiesApi) GetContext(ctx context.Context, name string) (*Entity, error) {
+	path := entitiesPath + "/" + url.QueryEscape(name)
+	jsonData, err := self.client.request(ctx, "GET", path, []byte{})
+	if err != nil {
+		return nil, err
+	}
+	var entity *Entity
+	err = json.Unmarshal([]byte(jsonData), &entity)
+	if err != nil {
+		return nil, &DecodeError{Body: jsonData, Err: err}
+	}
+	return entity, nil
+}
+
+func (self *entitiesApi) Delete(name string) error {
+	return self.DeleteContext(context.Background(), name)
+}
+func (self *entitiesApi) DeleteContext(ctx context.Context, name string) error {
+	path := entitiesPath + "/" + url.QueryEscape(name)
+	_, err := self.client.request(ctx, "DELETE", path, []byte{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Metrics lists the metrics collected for the entity, nil filter returns all of them
+func (self *entitiesApi) Metrics(name string, filter *EntityMetricsFilter) ([]*Metric, error) {
+	return self.MetricsContext(context.Background(), name, filter)
+}
+func (self *entitiesApi) MetricsContext(ctx context.Context, name string, filter *EntityMetricsFilter) ([]*Metric, error) {
+	path := entitiesPath + "/" + url.QueryEscape(name) + "/metrics"
+	if q := filter.values(); len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+	jsonData, err := self.client.request(ctx, "GET", path, []byte{})
+	if err != nil {
+		return nil, err
+	}
+
+	var metrics []*Metric
+	err = json.Unmarshal([]byte(jsonData), &metrics)
+	if err != nil {
+		return nil, &DecodeError{Body: jsonData, Err: err}
+	}
+
+	return metrics, nil
+}
+
+// PropertyTypes lists the types of properties stored for the entity
+func (self *entitiesApi) PropertyTypes(name string) ([]string, error) {
+	return self.PropertyTypesContext(context.Background(), name)
+}
+func (self *entitiesApi) PropertyTypesContext(ctx context.Context, name string) ([]string, error) {
+	path := entitiesPath + "/" + url.QueryEscape(name) + "/property-types"
+	jsonData, err := self.client.request(ctx, "GET", path, []byte{})
+	if err != nil {
+		return nil, err
+	}
+
+	var propertyTypes []string
+	err = json.Unmarshal([]byte(jsonData), &propertyTypes)
+	if err != nil {
+		return nil, &DecodeError{Body: jsonData, Err: err}
+	}
+
+	return propertyTypes, nil
+}
+
 type metricApi struct {
 	client *Client
 }
 
 func (self *metricApi) CreateOrReplace(metric *Metric) error {
+	return self.CreateOrReplaceContext(context.Background(), metric)
+}
+func (self *metricApi) CreateOrReplaceContext(ctx context.Context, metric *Metric) error {
 	jsonRequest, err := json.Marshal(metric)
 	if err != nil {
-		panic(err)
+		return &EncodeError{Err: err}
 	}
 	path := metricsPath + "/" + url.QueryEscape(metric.Name())
-	_, err = self.client.request("PUT", path, jsonRequest)
+	_, err = self.client.request(ctx, "PUT", path, jsonRequest)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// Update changes only the fields of the metric modified by setters since it was created with NewMetric
+// or fetched with Get, the other fields keep their values on the server
+func (self *metricApi) Update(metric *Metric) error {
+	return self.UpdateContext(context.Background(), metric)
+}
+func (self *metricApi) UpdateContext(ctx context.Context, metric *Metric) error {
+	jsonRequest, err := metric.patchJSON()
+	if err != nil {
+		return &EncodeError{Err: err}
+	}
+	path := metricsPath + "/" + url.QueryEscape(metric.Name())
+	_, err = self.client.request(ctx, "PATCH", path, jsonRequest)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (self *metricApi) Get(name string) (*Metric, error) {
+	return self.GetContext(context.Background(), name)
+}
+func (self *metricApi) GetContext(ctx context.Context, name string) (*Metric, error) {
+	path := metricsPath + "/" + url.QueryEscape(name)
+	jsonData, err := self.client.request(ctx, "GET", path, []byte{})
+	if err != nil {
+		return nil, err
+	}
+	var metric *Metric
+	err = json.Unmarshal([]byte(jsonData), &metric)
+	if err != nil {
+		return nil, &DecodeError{Body: jsonData, Err: err}
+	}
+	return metric, nil
+}
+
+func (self *metricApi) Delete(name string) error {
+	return self.DeleteContext(context.Background(), name)
+}
+func (self *metricApi) DeleteContext(ctx context.Context, name string) error {
+	path := metricsPath + "/" + url.QueryEscape(name)
+	_, err := self.client.request(ctx, "DELETE", path, []byte{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (self *metricApi) List(expression string, tags []string, limit uint64) ([]*Metric, error) {
+	return self.ListContext(context.Background(), expression, tags, limit)
+}
+func (self *metricApi) ListContext(ctx context.Context, expression string, tags []string, limit uint64) ([]*Metric, error) {
+	path := metricsPath
+	q := url.Values{}
+	q.Set("tags", tagsParam(tags))
+	q.Set("expression", expression)
+	q.Set("limit", strconv.FormatUint(limit, 10))
+	path += "?" + q.Encode()
+	jsonData, err := self.client.request(ctx, "GET", path, []byte{})
+	if err != nil {
+		return nil, err
+	}
+
+	var metrics []*Metric
+	err = json.Unmarshal([]byte(jsonData), &metrics)
+	if err != nil {
+		return nil, &DecodeError{Body: jsonData, Err: err}
+	}
+
+	return metrics, nil
+}
+
+// Series lists the series collected for the metric, empty entity and nil tags match all series
+func (self *metricApi) Series(name, entity string, tags map[string]string) ([]*SeriesDescriptor, error) {
+	return self.SeriesContext(context.Background(), name, entity, tags)
+}
+func (self *metricApi) SeriesContext(ctx context.Context, name, entity string, tags map[string]string) ([]*SeriesDescriptor, error) {
+	path := metricsPath + "/" + url.QueryEscape(name) + "/series"
+	q := url.Values{}
+	if entity != "" {
+		q.Set("entity", entity)
+	}
+	for key, val := range tags {
+		q.Set("tags."+key, val)
+	}
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+	jsonData, err := self.client.request(ctx, "GET", path, []byte{})
+	if err != nil {
+		return nil, err
+	}
+
+	var series []*SeriesDescriptor
+	err = json.Unmarshal([]byte(jsonData), &series)
+	if err != nil {
+		return nil, &DecodeError{Body: jsonData, Err: err}
+	}
+
+	return series, nil
+}
+
+// Entities lists the names of entities that have series for the metric
+func (self *metricApi) Entities(name string) ([]string, error) {
+	return self.EntitiesContext(context.Background(), name)
+}
+func (self *metricApi) EntitiesContext(ctx context.Context, name string) ([]string, error) {
+	series, err := self.SeriesContext(ctx, name, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	entities := []string{}
+	seen := map[string]bool{}
+	for _, s := range series {
+		if !seen[s.Entity] {
+			seen[s.Entity] = true
+			entities = append(entities, s.Entity)
+		}
+	}
+	return entities, nil
+}
+
 type messagesApi struct {
 	client *Client
 }
 
 func (self *messagesApi) Insert(messages []*Message) error {
+	return self.InsertContext(context.Background(), messages)
+}
+func (self *messagesApi) InsertContext(ctx context.Context, messages []*Message) error {
 	jsonRequest, err := json.Marshal(messages)
 	if err != nil {
-		panic(err)
+		return &EncodeError{Err: err}
 	}
-	_, err = self.client.request("POST", messagesInsertPath, jsonRequest)
+	err = self.client.insert(ctx, messagesInsertPath, jsonRequest)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 func (self *messagesApi) Query(query *MessagesQuery) ([]*Message, error) {
+	return self.QueryContext(context.Background(), query)
+}
+func (self *messagesApi) QueryContext(ctx context.Context, query *MessagesQuery) ([]*Message, error) {
 	jsonRequest, err := json.Marshal(query)
 	if err != nil {
-		panic(err)
+		return nil, &EncodeError{Err: err}
 	}
-	jsonData, err := self.client.request("POST", messagesQueryPath, jsonRequest)
+	jsonData, err := self.client.request(ctx, "POST", messagesQueryPath, jsonRequest)
 	if err != nil {
 		return nil, err
 	}
 	var messages []*Message
 	err = json.Unmarshal([]byte(jsonData), &messages)
 	if err != nil {
-		panic(err)
+		return nil, &DecodeError{Body: jsonData, Err: err}
 	}
 	return messages, nil
 }
 
+// Iterator pages through the messages matching query, pageSize messages per request (1000 if 0).
+// The query must have a start time, the end time is now if not set; its limit is ignored.
+func (self *messagesApi) Iterator(query *MessagesQuery, pageSize uint64) *MessageIterator {
+	return self.IteratorContext(context.Background(), query, pageSize)
+}
+func (self *messagesApi) IteratorContext(ctx context.Context, query *MessagesQuery, pageSize uint64) *MessageIterator {
+	return newMessageIterator(ctx, self, query, pageSize)
+}
+
 type entityGroupsApi struct {
 	client *Client
 }
 
 func (self *entityGroupsApi) EntitiesList(group, expression string, tags []string, limit uint64) ([]*Entity, error) {
-	tagsParams := ""
-	if len(tags) == 1 && tags[0] == "*" {
-		tagsParams = "*"
-	} else {
-		for i, tag := range tags {
-			if i == 0 {
-				tagsParams += tag
-			} else {
-				tagsParams += "," + tag
-			}
-		}
-	}
+	return self.EntitiesListContext(context.Background(), group, expression, tags, limit)
+}
+func (self *entityGroupsApi) EntitiesListContext(ctx context.Context, group, expression string, tags []string, limit uint64) ([]*Entity, error) {
 	entitiesUrl := &url.URL{Path: entitiesGroupPath + "/" + group + "/entities"}
 	path := entitiesUrl.String()
 	q := url.Values{}
-	q.Add("tags", tagsParams)
+	q.Add("tags", tagsParam(tags))
 	q.Add("expression", expression)
 	q.Add("limit", strconv.FormatUint(limit, 10))
 	path += "?" + q.Encode()
-	jsonData, err := self.client.request("GET", path, []byte{})
+	jsonData, err := self.client.request(ctx, "GET", path, []byte{})
 	if err != nil {
 		return nil, err
 	}
@@ -316,32 +950,33 @@ func (self *entityGroupsApi) EntitiesList(group, expression string, tags []strin
 	var entities []*Entity
 	err = json.Unmarshal([]byte(jsonData), &entities)
 	if err != nil {
-		panic(err)
+		return nil, &DecodeError{Body: jsonData, Err: err}
 	}
 
 	return entities, nil
 }
-func (self *entityGroupsApi) List(expression string, tags []string, limit uint64) ([]*EntityGroup, error) {
-	tagsParams := ""
-	if len(tags) == 1 && tags[0] == "*" {
-		tagsParams = "*"
-	} else {
-		for i, tag := range tags {
-			if i == 0 {
-				tagsParams += tag
-			} else {
-				tagsParams += "," + tag
-			}
-		}
-	}
 
+// EntitiesIterator pages through the entities of the group matching expression, see entitiesApi.Iterator
+func (self *entityGroupsApi) EntitiesIterator(group, expression string, tags []string, pageSize uint64) *EntityIterator {
+	return self.EntitiesIteratorContext(context.Background(), group, expression, tags, pageSize)
+}
+func (self *entityGroupsApi) EntitiesIteratorContext(ctx context.Context, group, expression string, tags []string, pageSize uint64) *EntityIterator {
+	return newEntityIterator(ctx, expression, pageSize, func(ctx context.Context, expression string, limit uint64) ([]*Entity, error) {
+		return self.EntitiesListContext(ctx, group, expression, tags, limit)
+	})
+}
+
+func (self *entityGroupsApi) List(expression string, tags []string, limit uint64) ([]*EntityGroup, error) {
+	return self.ListContext(context.Background(), expression, tags, limit)
+}
+func (self *entityGroupsApi) ListContext(ctx context.Context, expression string, tags []string, limit uint64) ([]*EntityGroup, error) {
 	path := entitiesGroupPath
 	q := url.Values{}
-	q.Set("tags", tagsParams)
+	q.Set("tags", tagsParam(tags))
 	q.Set("expression", expression)
 	q.Set("limit", strconv.FormatUint(limit, 10))
 	path += "?" + q.Encode()
-	jsonData, err := self.client.request("GET", path, []byte{})
+	jsonData, err := self.client.request(ctx, "GET", path, []byte{})
 	if err != nil {
 		return nil, err
 	}
@@ -349,23 +984,315 @@ func (self *entityGroupsApi) List(expression string, tags []string, limit uint64
 	var entityGroups []*EntityGroup
 	err = json.Unmarshal([]byte(jsonData), &entityGroups)
 	if err != nil {
-		panic(err)
+		return nil, &DecodeError{Body: jsonData, Err: err}
 	}
 
 	return entityGroups, nil
 }
 
+// Iterator pages through the entity groups matching expression, pageSize groups per request (1000 if 0)
+func (self *entityGroupsApi) Iterator(expression string, tags []string, pageSize uint64) *EntityGroupIterator {
+	return self.IteratorContext(context.Background(), expression, tags, pageSize)
+}
+func (self *entityGroupsApi) IteratorContext(ctx context.Context, expression string, tags []string, pageSize uint64) *EntityGroupIterator {
+	return newEntityGroupIterator(ctx, expression, pageSize, func(ctx context.Context, expression string, limit uint64) ([]*EntityGroup, error) {
+		return self.ListContext(ctx, expression, tags, limit)
+	})
+}
+
+func entityGroupPath(group string) string {
+	groupUrl := &url.URL{Path: entitiesGroupPath + "/" + group}
+	return groupUrl.String()
+}
+
+func (self *entityGroupsApi) Get(name string) (*EntityGroup, error) {
+	return self.GetContext(context.Background(), name)
+}
+func (self *entityGroupsApi) GetContext(ctx context.Context, name string) (*EntityGroup, error) {
+	jsonData, err := self.client.request(ctx, "GET", entityGroupPath(name), []byte{})
+	if err != nil {
+		return nil, err
+	}
+	var entityGroup *EntityGroup
+	err = json.Unmarshal([]byte(jsonData), &entityGroup)
+	if err != nil {
+		return nil, &DecodeError{Body: jsonData, Err: err}
+	}
+	return entityGroup, nil
+}
+
+func (self *entityGroupsApi) CreateOrReplace(entityGroup *EntityGroup) error {
+	return self.CreateOrReplaceContext(context.Background(), entityGroup)
+}
+func (self *entityGroupsApi) CreateOrReplaceContext(ctx context.Context, entityGroup *EntityGroup) error {
+	jsonRequest, err := json.Marshal(entityGroup)
+	if err != nil {
+		return &EncodeError{Err: err}
+	}
+	_, err = self.client.request(ctx, "PUT", entityGroupPath(entityGroup.Name), jsonRequest)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Update changes the expression and tags of the group, empty fields are left unchanged
+func (self *entityGroupsApi) Update(entityGroup *EntityGroup) error {
+	return self.UpdateContext(context.Background(), entityGroup)
+}
+func (self *entityGroupsApi) UpdateContext(ctx context.Context, entityGroup *EntityGroup) error {
+	jsonRequest, err := json.Marshal(entityGroup)
+	if err != nil {
+		return &EncodeError{Err: err}
+	}
+	_, err = self.client.request(ctx, "PATCH", entityGroupPath(entityGroup.Name), jsonRequest)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (self *entityGroupsApi) Delete(name string) error {
+	return self.DeleteContext(context.Background(), name)
+}
+func (self *entityGroupsApi) DeleteContext(ctx context.Context, name string) error {
+	_, err := self.client.request(ctx, "DELETE", entityGroupPath(name), []byte{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Preview lists the entities matching the expression of the group, i.e. the entities that
+// would join it if it was created
+func (self *entityGroupsApi) Preview(entityGroup *EntityGroup, limit uint64) ([]*Entity, error) {
+	return self.PreviewContext(context.Background(), entityGroup, limit)
+}
+func (self *entityGroupsApi) PreviewContext(ctx context.Context, entityGroup *EntityGroup, limit uint64) ([]*Entity, error) {
+	if entityGroup.Expression == "" {
+		return []*Entity{}, nil
+	}
+	return self.client.Entities.ListContext(ctx, entityGroup.Expression, nil, limit)
+}
+
+// AddEntities adds the entities to the group. The change is computed only if options.DryRun is set, otherwise nil is returned.
+func (self *entityGroupsApi) AddEntities(group string, entities []string, options *MembershipOptions) (*MembershipChange, error) {
+	return self.AddEntitiesContext(context.Background(), group, entities, options)
+}
+func (self *entityGroupsApi) AddEntitiesContext(ctx context.Context, group string, entities []string, options *MembershipOptions) (*MembershipChange, error) {
+	return self.changeEntities(ctx, group, "add", entities, options)
+}
+
+// SetEntities replaces all members of the group. The change is computed only if options.DryRun is set, otherwise nil is returned.
+func (self *entityGroupsApi) SetEntities(group string, entities []string, options *MembershipOptions) (*MembershipChange, error) {
+	return self.SetEntitiesContext(context.Background(), group, entities, options)
+}
+func (self *entityGroupsApi) SetEntitiesContext(ctx context.Context, group string, entities []string, options *MembershipOptions) (*MembershipChange, error) {
+	return self.changeEntities(ctx, group, "set", entities, options)
+}
+
+// DeleteEntities removes the entities from the group. The change is computed only if options.DryRun is set, otherwise nil is returned.
+func (self *entityGroupsApi) DeleteEntities(group string, entities []string, options *MembershipOptions) (*MembershipChange, error) {
+	return self.DeleteEntitiesContext(context.Background(), group, entities, options)
+}
+func (self *entityGroupsApi) DeleteEntitiesContext(ctx context.Context, group string, entities []string, options *MembershipOptions) (*MembershipChange, error) {
+	return self.changeEntities(ctx, group, "delete", entities, options)
+}
+
+func (self *entityGroupsApi) changeEntities(ctx context.Context, group, action string, entities []string, options *MembershipOptions) (*MembershipChange, error) {
+	if options == nil {
+		options = &MembershipOptions{}
+	}
+	if options.DryRun {
+		return self.planChange(ctx, group, action, entities)
+	}
+	jsonRequest, err := json.Marshal(entities)
+	if err != nil {
+		return nil, &EncodeError{Err: err}
+	}
+	path := entityGroupPath(group) + "/entities/" + action
+	if action != "delete" {
+		q := url.Values{}
+		q.Set("createEntities", strconv.FormatBool(options.CreateEntities))
+		path += "?" + q.Encode()
+	}
+	_, err = self.client.request(ctx, "POST", path, jsonRequest)
+	if err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+func (self *entityGroupsApi) planChange(ctx context.Context, group, action string, entities []string) (*MembershipChange, error) {
+	members, err := self.EntitiesListContext(ctx, group, "", nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	current := map[string]bool{}
+	for _, member := range members {
+		current[strings.ToLower(member.Name())] = true
+	}
+	requested := map[string]bool{}
+	for _, entity := range entities {
+		requested[strings.ToLower(entity)] = true
+	}
+
+	change := &MembershipChange{Added: []string{}, Removed: []string{}}
+	for _, entity := range entities {
+		name := strings.ToLower(entity)
+		switch action {
+		case "add", "set":
+			if !current[name] {
+				change.Added = append(change.Added, entity)
+				current[name] = true
+			}
+		case "delete":
+			if current[name] {
+				change.Removed = append(change.Removed, entity)
+				delete(current, name)
+			}
+		}
+	}
+	if action == "set" {
+		for _, member := range members {
+			if !requested[strings.ToLower(member.Name())] {
+				change.Removed = append(change.Removed, member.Name())
+			}
+		}
+	}
+	return change, nil
+}
+
+type alertsApi struct {
+	client *Client
+}
+
+func (self *alertsApi) Query(queries []*AlertsQuery) ([]*Alert, error) {
+	return self.QueryContext(context.Background(), queries)
+}
+func (self *alertsApi) QueryContext(ctx context.Context, queries []*AlertsQuery) ([]*Alert, error) {
+	for _, query := range queries {
+		// an empty list is omitted from the request and would match alerts of all severities
+		if query.Severities != nil && len(query.Severities) == 0 {
+			return nil, &ValueError{Field: "severities", Value: query.Severities}
+		}
+	}
+	jsonRequest, err := json.Marshal(queries)
+	if err != nil {
+		return nil, &EncodeError{Err: err}
+	}
+	jsonData, err := self.client.request(ctx, "POST", alertsQueryPath, jsonRequest)
+	if err != nil {
+		return nil, err
+	}
+	var alerts []*Alert
+	err = json.Unmarshal([]byte(jsonData), &alerts)
+	if err != nil {
+		return nil, &DecodeError{Body: jsonData, Err: err}
+	}
+	return alerts, nil
+}
+
+// Update acknowledges or unacknowledges alerts
+func (self *alertsApi) Update(updates []*AlertUpdate) error {
+	return self.UpdateContext(context.Background(), updates)
+}
+func (self *alertsApi) UpdateContext(ctx context.Context, updates []*AlertUpdate) error {
+	jsonRequest, err := json.Marshal(updates)
+	if err != nil {
+		return &EncodeError{Err: err}
+	}
+	_, err = self.client.request(ctx, "POST", alertsUpdatePath, jsonRequest)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (self *alertsApi) Delete(ids []uint64) error {
+	return self.DeleteContext(context.Background(), ids)
+}
+func (self *alertsApi) DeleteContext(ctx context.Context, ids []uint64) error {
+	request := make([]struct {
+		Id uint64 `json:"id"`
+	}, len(ids))
+	for i, id := range ids {
+		request[i].Id = id
+	}
+	jsonRequest, err := json.Marshal(request)
+	if err != nil {
+		return &EncodeError{Err: err}
+	}
+	_, err = self.client.request(ctx, "POST", alertsDeletePath, jsonRequest)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (self *alertsApi) History(queries []*AlertHistoryQuery) ([]*AlertHistory, error) {
+	return self.HistoryContext(context.Background(), queries)
+}
+func (self *alertsApi) HistoryContext(ctx context.Context, queries []*AlertHistoryQuery) ([]*AlertHistory, error) {
+	jsonRequest, err := json.Marshal(queries)
+	if err != nil {
+		return nil, &EncodeError{Err: err}
+	}
+	jsonData, err := self.client.request(ctx, "POST", alertsHistoryQueryPath, jsonRequest)
+	if err != nil {
+		return nil, err
+	}
+	var history []*AlertHistory
+	err = json.Unmarshal([]byte(jsonData), &history)
+	if err != nil {
+		return nil, &DecodeError{Body: jsonData, Err: err}
+	}
+	return history, nil
+}
+
+type commandsApi struct {
+	client *Client
+}
+
+// Send posts network commands (net.SeriesCommand, net.PropertyCommand, net.MessageCommand, ...) to the command endpoint
+func (self *commandsApi) Send(commands []fmt.Stringer) (*CommandResult, error) {
+	return self.SendContext(context.Background(), commands)
+}
+func (self *commandsApi) SendContext(ctx context.Context, commands []fmt.Stringer) (*CommandResult, error) {
+	var body bytes.Buffer
+	for _, command := range commands {
+		line := command.String()
+		body.WriteString(line)
+		if len(line) == 0 || line[len(line)-1] != '\n' {
+			body.WriteByte('\n')
+		}
+	}
+	jsonData, err := self.client.request(ctx, "POST", commandPath, body.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	var result *CommandResult
+	err = json.Unmarshal([]byte(jsonData), &result)
+	if err != nil {
+		return nil, &DecodeError{Body: jsonData, Err: err}
+	}
+	return result, nil
+}
+
 type sqlApi struct {
 	client *Client
 }
 
 func (self *sqlApi) Query(query string) (*Table, error) {
+	return self.QueryContext(context.Background(), query)
+}
+func (self *sqlApi) QueryContext(ctx context.Context, query string) (*Table, error) {
 	path := sql
 	params := url.Values{}
 	params.Set("q", query)
 	params.Set("outputFormat", "json")
 	path += "?" + params.Encode()
-	jsonData, err := self.client.request("GET", path, []byte{})
+	jsonData, err := self.client.request(ctx, "GET", path, []byte{})
 	if err != nil {
 		return nil, err
 	}
@@ -374,9 +1301,33 @@ func (self *sqlApi) Query(query string) (*Table, error) {
 	dec.UseNumber()
 	err = dec.Decode(&table)
 	if err != nil {
-		panic(err)
+		return nil, &DecodeError{Body: jsonData, Err: err}
 	}
 
 	return table, nil
 
 }
+
+// QueryRows executes the query and returns an iterator which decodes the result incrementally,
+// so arbitrarily large results are read at constant memory
+func (self *sqlApi) QueryRows(query string, format OutputFormat) (*Rows, error) {
+	return self.QueryRowsContext(context.Background(), query, format)
+}
+func (self *sqlApi) QueryRowsContext(ctx context.Context, query string, format OutputFormat) (*Rows, error) {
+	path := sql
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("outputFormat", string(format))
+	if format == CsvFormat {
+		params.Set("metadataFormat", "NONE")
+	}
+	path += "?" + params.Encode()
+	body, err := self.client.stream(ctx, "GET", path, []byte{})
+	if err != nil {
+		return nil, err
+	}
+	if format == CsvFormat {
+		return newCsvRows(body)
+	}
+	return newJsonRows(body)
+}
@@ -18,6 +18,8 @@ package http
 import (
 	"bytes"
 	"encoding/json"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -100,3 +102,43 @@ func (self *Entity) MarshalJSON() ([]byte, error) {
 
 	return json.Marshal(m)
 }
+
+// EntityMetricsFilter selects the metrics returned by Entities.Metrics, zero fields are not applied
+type EntityMetricsFilter struct {
+	Expression    string
+	Tags          []string
+	Enabled       *bool
+	Active        *bool //true selects metrics inserted within the last 24 hours
+	MinInsertDate *time.Time
+	MaxInsertDate *time.Time
+	Limit         uint64
+}
+
+func (self *EntityMetricsFilter) values() url.Values {
+	q := url.Values{}
+	if self == nil {
+		return q
+	}
+	if self.Expression != "" {
+		q.Set("expression", self.Expression)
+	}
+	if len(self.Tags) > 0 {
+		q.Set("tags", tagsParam(self.Tags))
+	}
+	if self.Enabled != nil {
+		q.Set("enabled", strconv.FormatBool(*self.Enabled))
+	}
+	if self.Active != nil {
+		q.Set("active", strconv.FormatBool(*self.Active))
+	}
+	if self.MinInsertDate != nil {
+		q.Set("minInsertDate", self.MinInsertDate.UTC().Format(time.RFC3339Nano))
+	}
+	if self.MaxInsertDate != nil {
+		q.Set("maxInsertDate", self.MaxInsertDate.UTC().Format(time.RFC3339Nano))
+	}
+	if self.Limit > 0 {
+		q.Set("limit", strconv.FormatUint(self.Limit, 10))
+	}
+	return q
+}
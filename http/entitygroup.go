@@ -2,6 +2,18 @@ package http
 
 type EntityGroup struct {
 	Name       string            `json:"name"`
-	Expression string            `json:"expression"`
-	Tags       map[string]string `json:"tags"`
+	Expression string            `json:"expression,omitempty"` //entities matching the expression join the group automatically
+	Tags       map[string]string `json:"tags,omitempty"`
+}
+
+// MembershipOptions control how member entities of a group are changed
+type MembershipOptions struct {
+	CreateEntities bool //create entities that do not exist yet
+	DryRun         bool //compute the change without modifying the group
+}
+
+// MembershipChange lists the entities that join and leave a group
+type MembershipChange struct {
+	Added   []string
+	Removed []string
 }
@@ -0,0 +1,139 @@
+/*
+* Copyright 2015 Axibase Corporation or its affiliates. All Rights Reserved.
+*
+* Licensed under the Apache License, Version 2.0 (the "License").
+* You may not use this file except in compliance with the License.
+* A copy of the License is located at
+*
+* https://www.axibase.com/atsd/axibase-apache-2.0.pdf
+*
+* or in the "license" file accompanying this file. This file is distributed
+* on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+* express or implied. See the License for the specific language governing
+* permissions and limitations under the License.
+ */
+
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+const maxErrorBodyLength = 256
+
+// RequestError is returned when an HTTP request could not be constructed or authenticated
+type RequestError struct {
+	Method string
+	Path   string
+	Err    error
+}
+
+func (self *RequestError) Error() string {
+	return fmt.Sprintf("http: could not create %v %v request: %v", self.Method, self.Path, self.Err)
+}
+func (self *RequestError) Unwrap() error {
+	return self.Err
+}
+
+// EncodeError is returned when a request body could not be encoded to JSON
+type EncodeError struct {
+	Err error
+}
+
+func (self *EncodeError) Error() string {
+	return fmt.Sprintf("http: could not encode request: %v", self.Err)
+}
+func (self *EncodeError) Unwrap() error {
+	return self.Err
+}
+
+// DecodeError is returned when a response body could not be decoded,
+// e.g. when the server responds with an HTML error page
+type DecodeError struct {
+	Body string
+	Err  error
+}
+
+func (self *DecodeError) Error() string {
+	body := self.Body
+	if len(body) > maxErrorBodyLength {
+		body = body[:maxErrorBodyLength] + "..."
+	}
+	return fmt.Sprintf("http: could not decode response: %v, body: %q", self.Err, body)
+}
+func (self *DecodeError) Unwrap() error {
+	return self.Err
+}
+
+// ValueError is returned when a JSON field holds a value of an unexpected type
+type ValueError struct {
+	Field string
+	Value interface{}
+}
+
+func (self *ValueError) Error() string {
+	return fmt.Sprintf("http: invalid value %#v of field %q", self.Value, self.Field)
+}
+
+// APIError is returned when the server responds with an error status code
+// or with an {"error": ...} body
+type APIError struct {
+	StatusCode int
+	Method     string
+	Path       string
+	Message    string //error message reported by the server, empty if the body is not an ATSD error
+	Body       string
+	Header     http.Header
+}
+
+func (self *APIError) Error() string {
+	message := self.Message
+	if message == "" {
+		message = http.StatusText(self.StatusCode)
+	}
+	return fmt.Sprintf("http: %v %v: %v %v", self.Method, self.Path, self.StatusCode, message)
+}
+
+func (self *APIError) IsNotFound() bool {
+	return self.StatusCode == http.StatusNotFound
+}
+func (self *APIError) IsUnauthorized() bool {
+	return self.StatusCode == http.StatusUnauthorized
+}
+func (self *APIError) IsForbidden() bool {
+	return self.StatusCode == http.StatusForbidden
+}
+
+// IsRetryable reports whether the same request may succeed if sent again later
+func (self *APIError) IsRetryable() bool {
+	switch self.StatusCode {
+	case http.StatusRequestTimeout,
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+func IsNotFound(err error) bool {
+	var apiError *APIError
+	return errors.As(err, &apiError) && apiError.IsNotFound()
+}
+func IsUnauthorized(err error) bool {
+	var apiError *APIError
+	return errors.As(err, &apiError) && apiError.IsUnauthorized()
+}
+func IsForbidden(err error) bool {
+	var apiError *APIError
+	return errors.As(err, &apiError) && apiError.IsForbidden()
+}
+func IsRetryable(err error) bool {
+	var apiError *APIError
+	return errors.As(err, &apiError) && apiError.IsRetryable()
+}
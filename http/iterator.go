@@ -0,0 +1,292 @@
+/*
+* Copyright 2015 Axibase Corporation or its affiliates. All Rights Reserved.
+*
+* Licensed under the Apache License, Version 2.0 (the "License").
+* You may not use this file except in compliance with the License.
+* A copy of the License is located at
+*
+* https://www.axibase.com/atsd/axibase-apache-2.0.pdf
+*
+* or in the "license" file accompanying this file. This file is distributed
+* on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+* express or implied. See the License for the specific language governing
+* permissions and limitations under the License.
+ */
+
+package http
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/axibase/atsd-api-go/net"
+	"github.com/golang/glog"
+)
+
+const defaultPageSize = 1000
+
+// namePager requests pages of items ordered by name. Each page is requested with the name of
+// the last item received as a cursor, so no item is returned twice.
+type namePager struct {
+	ctx        context.Context
+	expression string
+	pageSize   uint64
+	fetch      func(ctx context.Context, expression string, limit uint64) ([]string, error) //requests a page, returns the names of its items
+
+	remaining int
+	cursor    string
+	last      bool
+	err       error
+}
+
+func newNamePager(ctx context.Context, expression string, pageSize uint64, fetch func(context.Context, string, uint64) ([]string, error)) namePager {
+	if pageSize == 0 {
+		pageSize = defaultPageSize
+	}
+	return namePager{ctx: ctx, expression: expression, pageSize: pageSize, fetch: fetch}
+}
+
+// next reports whether one more item is available, requesting the next page when the current one is exhausted
+func (self *namePager) next() bool {
+	if self.err != nil {
+		return false
+	}
+	if err := self.ctx.Err(); err != nil {
+		self.err = err
+		return false
+	}
+	if self.remaining == 0 {
+		if self.last {
+			return false
+		}
+		names, err := self.fetch(self.ctx, cursorExpression(self.expression, self.cursor), self.pageSize)
+		if err != nil {
+			self.err = err
+			return false
+		}
+		self.last = uint64(len(names)) < self.pageSize
+		if len(names) == 0 {
+			return false
+		}
+		self.remaining = len(names)
+		self.cursor = names[len(names)-1]
+	}
+	self.remaining--
+	return true
+}
+
+// cursorExpression restricts expression to names following cursor
+func cursorExpression(expression, cursor string) string {
+	if cursor == "" {
+		return expression
+	}
+	condition := "name > " + QuoteString(cursor)
+	if expression == "" {
+		return condition
+	}
+	return "(" + expression + ") AND " + condition
+}
+
+// EntityIterator pages through entities in the order of their names
+//
+//	it := client.Entities.Iterator("", nil, 0)
+//	for it.Next() {
+//		entity := it.Entity()
+//		...
+//	}
+//	err := it.Err()
+type EntityIterator struct {
+	pager  namePager
+	page   []*Entity
+	entity *Entity
+}
+
+func newEntityIterator(ctx context.Context, expression string, pageSize uint64, fetch func(context.Context, string, uint64) ([]*Entity, error)) *EntityIterator {
+	iterator := &EntityIterator{}
+	iterator.pager = newNamePager(ctx, expression, pageSize, func(ctx context.Context, expression string, limit uint64) ([]string, error) {
+		page, err := fetch(ctx, expression, limit)
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, len(page))
+		for i, entity := range page {
+			names[i] = entity.Name()
+		}
+		iterator.page = page
+		return names, nil
+	})
+	return iterator
+}
+
+// Next advances to the next entity, requesting the next page when the current one is exhausted.
+// It returns false when there are no more entities, an error occurred or the context is done.
+func (self *EntityIterator) Next() bool {
+	if !self.pager.next() {
+		return false
+	}
+	self.entity, self.page = self.page[0], self.page[1:]
+	return true
+}
+
+func (self *EntityIterator) Entity() *Entity {
+	return self.entity
+}
+
+func (self *EntityIterator) Err() error {
+	return self.pager.err
+}
+
+// EntityGroupIterator pages through entity groups in the order of their names, see EntityIterator
+type EntityGroupIterator struct {
+	pager       namePager
+	page        []*EntityGroup
+	entityGroup *EntityGroup
+}
+
+func newEntityGroupIterator(ctx context.Context, expression string, pageSize uint64, fetch func(context.Context, string, uint64) ([]*EntityGroup, error)) *EntityGroupIterator {
+	iterator := &EntityGroupIterator{}
+	iterator.pager = newNamePager(ctx, expression, pageSize, func(ctx context.Context, expression string, limit uint64) ([]string, error) {
+		page, err := fetch(ctx, expression, limit)
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, len(page))
+		for i, entityGroup := range page {
+			names[i] = entityGroup.Name
+		}
+		iterator.page = page
+		return names, nil
+	})
+	return iterator
+}
+
+func (self *EntityGroupIterator) Next() bool {
+	if !self.pager.next() {
+		return false
+	}
+	self.entityGroup, self.page = self.page[0], self.page[1:]
+	return true
+}
+
+func (self *EntityGroupIterator) EntityGroup() *EntityGroup {
+	return self.entityGroup
+}
+
+func (self *EntityGroupIterator) Err() error {
+	return self.pager.err
+}
+
+// MessageIterator pages through messages from the newest to the oldest. Each page is requested
+// with the end of the time window moved to the oldest message received, messages sharing that
+// timestamp are not returned twice.
+type MessageIterator struct {
+	ctx      context.Context
+	query    MessagesQuery
+	pageSize uint64
+	api      *messagesApi
+
+	page    []*Message
+	end     time.Time
+	seen    int //number of received messages with the timestamp equal to end - 1ms
+	last    bool
+	message *Message
+	err     error
+}
+
+func (self *MessageIterator) Next() bool {
+	if self.err != nil {
+		return false
+	}
+	if err := self.ctx.Err(); err != nil {
+		self.err = err
+		return false
+	}
+	for len(self.page) == 0 {
+		if self.last {
+			return false
+		}
+		if err := self.fetch(); err != nil {
+			self.err = err
+			return false
+		}
+	}
+	self.message, self.page = self.page[0], self.page[1:]
+	return true
+}
+
+// fetch requests the window [start, end) and moves end to the oldest timestamp received
+func (self *MessageIterator) fetch() error {
+	query := self.query
+	query.SetEndDateTime(self.end)
+	query.SetLimit(self.pageSize)
+	page, err := self.api.QueryContext(self.ctx, &query)
+	if err != nil {
+		return err
+	}
+	self.last = uint64(len(page)) < self.pageSize
+	if len(page) == 0 {
+		return nil
+	}
+
+	boundary := net.Millis(self.end.UnixNano()/1e6 - 1)
+	oldest := boundary
+	for _, message := range page {
+		timestamp := message.Timestamp()
+		if timestamp == nil {
+			return errors.New("http: message iterator received a message without timestamp")
+		}
+		if *timestamp < oldest {
+			oldest = *timestamp
+		}
+	}
+	// messages at the previous boundary were partly received with the last page
+	skip := 0
+	for skip < len(page) && skip < self.seen && *page[skip].Timestamp() == boundary {
+		skip++
+	}
+	self.page = page[skip:]
+
+	if self.last {
+		return nil
+	}
+	if oldest == boundary {
+		// the whole page shares the boundary timestamp, messages beyond it can not be requested
+		glog.Warningf("messages: at least %v messages share the timestamp %v, the rest of them are skipped", self.pageSize, oldest)
+		self.end = time.Unix(0, int64(oldest)*1e6)
+		self.seen = 0
+		return nil
+	}
+	self.end = time.Unix(0, (int64(oldest)+1)*1e6)
+	self.seen = 0
+	for _, message := range self.page {
+		if *message.Timestamp() == oldest {
+			self.seen++
+		}
+	}
+	return nil
+}
+
+func (self *MessageIterator) Message() *Message {
+	return self.message
+}
+
+func (self *MessageIterator) Err() error {
+	return self.err
+}
+
+func newMessageIterator(ctx context.Context, api *messagesApi, query *MessagesQuery, pageSize uint64) *MessageIterator {
+	if pageSize == 0 {
+		pageSize = defaultPageSize
+	}
+	iterator := &MessageIterator{ctx: ctx, query: *query, pageSize: pageSize, api: api}
+	if query.StartDateTime() == nil {
+		iterator.err = errors.New("http: message iterator requires the start of the time window")
+	}
+	if end := query.EndDateTime(); end != nil {
+		iterator.end = *end
+	} else {
+		iterator.end = time.Now()
+	}
+	return iterator
+}
@@ -0,0 +1,195 @@
+/*
+* Copyright 2015 Axibase Corporation or its affiliates. All Rights Reserved.
+*
+* Licensed under the Apache License, Version 2.0 (the "License").
+* You may not use this file except in compliance with the License.
+* A copy of the License is located at
+*
+* https://www.axibase.com/atsd/axibase-apache-2.0.pdf
+*
+* or in the "license" file accompanying this file. This file is distributed
+* on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+* express or implied. See the License for the specific language governing
+* permissions and limitations under the License.
+ */
+
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testMessage struct {
+	text      string
+	timestamp int64 //milliseconds, messages without timestamp have -1
+}
+
+// newMessageClient serves messages, which must be sorted from the newest to the oldest,
+// like the server does: the newest messages in [startTime, endTime) up to the limit
+func newMessageClient(t *testing.T, messages []testMessage) (*Client, *int32) {
+	return newTestClient(t, testRetryPolicy(), func(n int32, w http.ResponseWriter, r *http.Request) {
+		var query struct {
+			StartTime int64  `json:"startTime"`
+			EndTime   int64  `json:"endTime"`
+			Limit     uint64 `json:"limit"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		page := []map[string]interface{}{}
+		for _, message := range messages {
+			if uint64(len(page)) == query.Limit {
+				break
+			}
+			jsonMessage := map[string]interface{}{"entity": "e", "message": message.text}
+			if message.timestamp >= 0 {
+				if message.timestamp < query.StartTime || message.timestamp >= query.EndTime {
+					continue
+				}
+				jsonMessage["date"] = time.Unix(0, message.timestamp*1e6).UTC().Format(time.RFC3339Nano)
+			}
+			page = append(page, jsonMessage)
+		}
+		json.NewEncoder(w).Encode(page)
+	})
+}
+
+func messagesQuery() *MessagesQuery {
+	return NewMessagesQuery("e").SetStartDateTime(time.Unix(0, 1e6)).SetEndDateTime(time.Unix(0, 100e6))
+}
+
+func TestMessageIterator(t *testing.T) {
+	tests := []struct {
+		name       string
+		timestamps []int64
+		pageSize   uint64
+		expected   []int //indexes of the received messages
+	}{
+		{"one page", []int64{30, 20, 10}, 5, []int{0, 1, 2}},
+		{"last page is full", []int64{60, 50, 40, 30, 20, 10}, 3, []int{0, 1, 2, 3, 4, 5}},
+		{"same timestamp across pages", []int64{10, 10, 9, 9, 8}, 3, []int{0, 1, 2, 3, 4}},
+		{"page ends with the same timestamp", []int64{10, 9, 9, 9, 8, 7}, 3, []int{0, 1, 2, 3, 4, 5}},
+		{"more than a page share timestamp", []int64{10, 9, 9, 9, 9, 8}, 3, []int{0, 1, 2, 3, 5}},
+		{"no messages", nil, 3, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var messages []testMessage
+			for i, timestamp := range test.timestamps {
+				messages = append(messages, testMessage{text: fmt.Sprint(i), timestamp: timestamp})
+			}
+			client, _ := newMessageClient(t, messages)
+			iterator := client.Messages.Iterator(messagesQuery(), test.pageSize)
+			var received []string
+			for iterator.Next() && len(received) <= len(messages) {
+				message := iterator.Message()
+				received = append(received, fmt.Sprintf("%v@%v", message.Message(), *message.Timestamp()))
+			}
+			if err := iterator.Err(); err != nil {
+				t.Fatal(err)
+			}
+			var expected []string
+			for _, i := range test.expected {
+				expected = append(expected, fmt.Sprintf("%v@%v", i, messages[i].timestamp))
+			}
+			if fmt.Sprint(received) != fmt.Sprint(expected) {
+				t.Errorf("expected %v, received %v", expected, received)
+			}
+		})
+	}
+}
+
+func TestMessageIteratorRejectsMessagesWithoutTimestamp(t *testing.T) {
+	client, requests := newMessageClient(t, []testMessage{{"a", -1}, {"b", -1}, {"c", -1}})
+	iterator := client.Messages.Iterator(messagesQuery(), 2)
+	for iterator.Next() {
+		t.Errorf("unexpected message %v", iterator.Message())
+	}
+	if iterator.Err() == nil {
+		t.Error("expected an error for messages without timestamp")
+	}
+	if *requests != 1 {
+		t.Errorf("expected 1 request, got %v", *requests)
+	}
+}
+
+func TestMessageIteratorCanceled(t *testing.T) {
+	client, requests := newMessageClient(t, []testMessage{{"a", 30}, {"b", 20}, {"c", 10}})
+	ctx, cancel := context.WithCancel(context.Background())
+	iterator := client.Messages.IteratorContext(ctx, messagesQuery(), 1)
+	if !iterator.Next() || iterator.Message().Message() != "a" {
+		t.Fatalf("expected the first message, got %v", iterator.Err())
+	}
+	cancel()
+	if iterator.Next() {
+		t.Errorf("unexpected message %v after cancel", iterator.Message())
+	}
+	if !errors.Is(iterator.Err(), context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", iterator.Err())
+	}
+	if *requests != 1 {
+		t.Errorf("expected 1 request, got %v", *requests)
+	}
+}
+
+func TestMessageIteratorRequiresStart(t *testing.T) {
+	client, requests := newMessageClient(t, nil)
+	iterator := client.Messages.Iterator(NewMessagesQuery("e"), 0)
+	if iterator.Next() || iterator.Err() == nil {
+		t.Error("expected an error without start time")
+	}
+	if *requests != 0 {
+		t.Errorf("expected no requests, got %v", *requests)
+	}
+}
+
+func TestEntityIterator(t *testing.T) {
+	names := []string{"a", "b", "c", "it's", "z"}
+	var expressions []string
+	client, _ := newTestClient(t, testRetryPolicy(), func(n int32, w http.ResponseWriter, r *http.Request) {
+		expression := r.URL.Query().Get("expression")
+		limit := 0
+		fmt.Sscan(r.URL.Query().Get("limit"), &limit)
+		expressions = append(expressions, expression)
+		cursor := ""
+		if i := strings.Index(expression, "name > '"); i >= 0 {
+			cursor = strings.Replace(strings.TrimSuffix(expression[i+len("name > '"):], "'"), "''", "'", -1)
+		}
+		page := []map[string]string{}
+		for _, name := range names[sort.SearchStrings(names, cursor):] {
+			if name > cursor && len(page) < limit {
+				page = append(page, map[string]string{"name": name})
+			}
+		}
+		json.NewEncoder(w).Encode(page)
+	})
+
+	iterator := client.Entities.Iterator("tags.env = 'prod'", nil, 2)
+	var received []string
+	for iterator.Next() {
+		received = append(received, iterator.Entity().Name())
+	}
+	if err := iterator.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(received) != fmt.Sprint(names) {
+		t.Errorf("expected %v, received %v", names, received)
+	}
+	expected := []string{
+		"tags.env = 'prod'",
+		"(tags.env = 'prod') AND name > 'b'",
+		"(tags.env = 'prod') AND name > 'it''s'",
+	}
+	if fmt.Sprint(expressions) != fmt.Sprint(expected) {
+		t.Errorf("expected expressions %q, got %q", expected, expressions)
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"strings"
+	"time"
 
 	"github.com/axibase/atsd-api-go/net"
 )
@@ -132,35 +133,74 @@ func (self *Message) UnmarshalJSON(data []byte) error {
 	if err := dec.Decode(&jsonMap); err != nil {
 		return err
 	}
-	if entity, ok := jsonMap["entity"]; ok {
-		self.entity = entity.(string)
+	if iEntity, ok := jsonMap["entity"]; ok {
+		entity, ok := iEntity.(string)
+		if !ok {
+			return &ValueError{Field: "entity", Value: iEntity}
+		}
+		self.entity = entity
 	}
-	if message, ok := jsonMap["message"]; ok {
-		self.message = message.(string)
+	if iMessage, ok := jsonMap["message"]; ok {
+		message, ok := iMessage.(string)
+		if !ok {
+			return &ValueError{Field: "message", Value: iMessage}
+		}
+		self.message = message
 	}
 	if iTimestamp, ok := jsonMap["timestamp"]; ok {
-		timestamp := iTimestamp.(json.Number)
-		t, _ := timestamp.Int64()
+		timestamp, ok := iTimestamp.(json.Number)
+		if !ok {
+			return &ValueError{Field: "timestamp", Value: iTimestamp}
+		}
+		t, err := timestamp.Int64()
+		if err != nil {
+			return &ValueError{Field: "timestamp", Value: iTimestamp}
+		}
 		self.SetTimestamp(net.Millis(t))
+	} else if iDate, ok := jsonMap["date"]; ok {
+		date, ok := iDate.(string)
+		if !ok {
+			return &ValueError{Field: "date", Value: iDate}
+		}
+		t, err := time.Parse(time.RFC3339Nano, date)
+		if err != nil {
+			return &ValueError{Field: "date", Value: iDate}
+		}
+		self.SetTimestamp(net.Millis(t.UnixNano() / 1e6))
 	}
 	if iSeverity, ok := jsonMap["severity"]; ok {
-		severity := iSeverity.(string)
+		severity, ok := iSeverity.(string)
+		if !ok {
+			return &ValueError{Field: "severity", Value: iSeverity}
+		}
 		s := Severity(severity)
 		self.severity = &(s)
 	}
 	if iType, ok := jsonMap["type"]; ok {
-		mType := iType.(string)
+		mType, ok := iType.(string)
+		if !ok {
+			return &ValueError{Field: "type", Value: iType}
+		}
 		self.mType = &(mType)
 	}
 	if iSource, ok := jsonMap["source"]; ok {
-		iSource := iSource.(string)
-		self.source = &(iSource)
+		source, ok := iSource.(string)
+		if !ok {
+			return &ValueError{Field: "source", Value: iSource}
+		}
+		self.source = &(source)
 	}
 	if tags, ok := jsonMap["tags"]; ok {
 		self.tags = map[string]string{}
-		t := tags.(map[string]interface{})
+		t, ok := tags.(map[string]interface{})
+		if !ok {
+			return &ValueError{Field: "tags", Value: tags}
+		}
 		for key, val := range t {
-			str := val.(string)
+			str, ok := val.(string)
+			if !ok {
+				return &ValueError{Field: "tags." + key, Value: val}
+			}
 			self.tags[key] = str
 		}
 	}
@@ -16,6 +16,7 @@
 package http
 
 import (
+	"bytes"
 	"encoding/json"
 	"strings"
 	"time"
@@ -109,6 +110,8 @@ type Metric struct {
 	retentionInterval Days              //Number of days to retain values for this metric in the database
 	lastInsertTime    *time.Time        //Last time value was received by ATSD for this metric. Time specified in epoch milliseconds.
 	tags              map[string]string //as requested by tags parameter
+
+	changed map[string]bool //fields and "tags.name" keys modified by setters, sent by Update
 }
 
 func NewMetric(name string) *Metric {
@@ -130,54 +133,67 @@ func (self *Metric) SetName(name string) *Metric {
 	return self
 }
 func (self *Metric) SetEnabled(isEnabled bool) *Metric {
+	self.markChanged("enabled")
 	self.enabled = isEnabled
 	return self
 }
 func (self *Metric) SetDataType(dataType DataType) *Metric {
+	self.markChanged("dataType")
 	self.dataType = dataType
 	return self
 }
 func (self *Metric) SetCounter(isCounter bool) *Metric {
+	self.markChanged("counter")
 	self.counter = isCounter
 	return self
 }
 func (self *Metric) SetPersistent(isPersistent bool) *Metric {
+	self.markChanged("persistent")
 	self.persistent = isPersistent
 	return self
 }
 func (self *Metric) SetTag(name, value string) *Metric {
+	self.markChanged("tags." + strings.ToLower(name))
 	self.tags[strings.ToLower(name)] = value
 	return self
 }
 func (self *Metric) SetTimePrecision(timePrecision TimePrecision) *Metric {
+	self.markChanged("timePrecision")
 	self.timePrecision = timePrecision
 	return self
 }
 func (self *Metric) SetRetentionInterval(retentionInterval Days) *Metric {
+	self.markChanged("retentionInterval")
 	self.retentionInterval = retentionInterval
 	return self
 }
 func (self *Metric) SetInvalidAction(invalidAction InvalidAction) *Metric {
+	self.markChanged("invalidAction")
 	self.invalidAction = invalidAction
 	return self
 }
 func (self *Metric) SetLabel(label string) *Metric {
+	self.markChanged("label")
 	self.label = &label
 	return self
 }
 func (self *Metric) SetFilter(filter string) *Metric {
+	self.markChanged("filter")
 	self.filter = &filter
 	return self
 }
 func (self *Metric) SetMinValue(minValue net.Number) *Metric {
+	self.markChanged("minValue")
 	self.minValue = &minValue
 	return self
 }
 func (self *Metric) SetMaxValue(maxValue net.Number) *Metric {
+	self.markChanged("maxValue")
 	self.maxValue = &maxValue
 	return self
 }
 func (self *Metric) SetDescription(description string) *Metric {
+	self.markChanged("description")
 	self.description = &description
 	return self
 }
@@ -228,6 +244,49 @@ func (self *Metric) Description() *string {
 func (self *Metric) GetLastInsertTime() *time.Time {
 	return self.lastInsertTime
 }
+func (self *Metric) Tags() map[string]string {
+	copy := map[string]string{}
+	for k, v := range self.tags {
+		copy[k] = v
+	}
+	return copy
+}
+func (self *Metric) markChanged(field string) {
+	if self.changed == nil {
+		self.changed = map[string]bool{}
+	}
+	self.changed[field] = true
+}
+
+// patchJSON encodes only the fields modified by setters since the metric was created or fetched,
+// so that an update does not reset the other fields stored on the server
+func (self *Metric) patchJSON() ([]byte, error) {
+	full := map[string]interface{}{}
+	data, err := self.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&full); err != nil {
+		return nil, err
+	}
+	m := map[string]interface{}{}
+	tags := map[string]string{}
+	for field := range self.changed {
+		if strings.HasPrefix(field, "tags.") {
+			name := strings.TrimPrefix(field, "tags.")
+			tags[name] = self.tags[name]
+		} else if value, ok := full[field]; ok {
+			m[field] = value
+		}
+	}
+	if len(tags) > 0 {
+		m["tags"] = tags
+	}
+	return json.Marshal(m)
+}
+
 func (self *Metric) MarshalJSON() ([]byte, error) {
 	m := map[string]interface{}{}
 	m["name"] = self.name
@@ -261,3 +320,114 @@ func (self *Metric) MarshalJSON() ([]byte, error) {
 	}
 	return json.Marshal(m)
 }
+func (self *Metric) UnmarshalJSON(data []byte) error {
+	var jsonMap map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&jsonMap); err != nil {
+		return err
+	}
+	*self = *NewMetric("")
+	self.name, _ = jsonMap["name"].(string)
+	if enabled, ok := jsonMap["enabled"].(bool); ok {
+		self.enabled = enabled
+	}
+	if counter, ok := jsonMap["counter"].(bool); ok {
+		self.counter = counter
+	}
+	if persistent, ok := jsonMap["persistent"].(bool); ok {
+		self.persistent = persistent
+	}
+	if dataType, ok := jsonMap["dataType"].(string); ok {
+		self.dataType = parseDataType(dataType)
+	}
+	if timePrecision, ok := jsonMap["timePrecision"].(string); ok {
+		self.timePrecision = parseTimePrecision(timePrecision)
+	}
+	if invalidAction, ok := jsonMap["invalidAction"].(string); ok {
+		self.invalidAction = parseInvalidAction(invalidAction)
+	}
+	if retentionInterval, ok := jsonMap["retentionInterval"].(json.Number); ok {
+		days, err := retentionInterval.Int64()
+		if err != nil || days < 0 {
+			return &ValueError{Field: "retentionInterval", Value: retentionInterval}
+		}
+		self.retentionInterval = Days(days)
+	}
+	if label, ok := jsonMap["label"].(string); ok {
+		self.label = &label
+	}
+	if filter, ok := jsonMap["filter"].(string); ok {
+		self.filter = &filter
+	}
+	if description, ok := jsonMap["description"].(string); ok {
+		self.description = &description
+	}
+	if minValue, ok := jsonMap["minValue"].(json.Number); ok {
+		number, err := parseNumber(minValue)
+		if err != nil {
+			return &ValueError{Field: "minValue", Value: minValue}
+		}
+		self.minValue = &number
+	}
+	if maxValue, ok := jsonMap["maxValue"].(json.Number); ok {
+		number, err := parseNumber(maxValue)
+		if err != nil {
+			return &ValueError{Field: "maxValue", Value: maxValue}
+		}
+		self.maxValue = &number
+	}
+	if lastInsertTimeString, ok := jsonMap["lastInsertTime"].(json.Number); ok {
+		lastInsertTimeInt, _ := lastInsertTimeString.Int64()
+		lastInsertTime := time.Unix(0, lastInsertTimeInt*1e6)
+		self.lastInsertTime = &lastInsertTime
+	} else if lastInsertDateString, ok := jsonMap["lastInsertDate"].(string); ok {
+		lastInsertDate, err := time.Parse(time.RFC3339Nano, lastInsertDateString)
+		if err != nil {
+			return &ValueError{Field: "lastInsertDate", Value: lastInsertDateString}
+		}
+		self.lastInsertTime = &lastInsertDate
+	}
+	m, _ := jsonMap["tags"].(map[string]interface{})
+	for key, val := range m {
+		self.tags[key], _ = val.(string)
+	}
+	return nil
+}
+
+func parseDataType(dataType string) DataType {
+	switch strings.ToUpper(dataType) {
+	case "SHORT":
+		return SHORT
+	case "INTEGER":
+		return INTEGER
+	case "LONG":
+		return LONG
+	case "DOUBLE":
+		return DOUBLE
+	default:
+		return FLOAT
+	}
+}
+
+func parseTimePrecision(timePrecision string) TimePrecision {
+	switch strings.ToUpper(timePrecision) {
+	case "SECONDS":
+		return SECONDS
+	default:
+		return MILLISECONDS
+	}
+}
+
+func parseInvalidAction(invalidAction string) InvalidAction {
+	switch strings.ToUpper(invalidAction) {
+	case "DISCARD":
+		return DISCARD
+	case "TRANSFORM":
+		return TRANSFORM
+	case "RAISE_ERROR":
+		return RAISE_ERROR
+	default:
+		return NONE
+	}
+}
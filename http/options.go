@@ -0,0 +1,239 @@
+/*
+* Copyright 2015 Axibase Corporation or its affiliates. All Rights Reserved.
+*
+* Licensed under the Apache License, Version 2.0 (the "License").
+* You may not use this file except in compliance with the License.
+* A copy of the License is located at
+*
+* https://www.axibase.com/atsd/axibase-apache-2.0.pdf
+*
+* or in the "license" file accompanying this file. This file is distributed
+* on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+* express or implied. See the License for the specific language governing
+* permissions and limitations under the License.
+ */
+
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type options struct {
+	httpClient *http.Client
+	transport  http.RoundTripper
+	timeout    *time.Duration
+
+	insecureSkipVerify bool
+	rootCAs            *x509.CertPool
+	certificates       []tls.Certificate
+	proxy              func(*http.Request) (*url.URL, error)
+
+	userAgent     string
+	headers       http.Header
+	authenticator Authenticator
+	retryPolicy   *RetryPolicy
+	gzipMinSize   *int
+	chunking      ChunkConfig
+}
+
+// customTls reports whether TLS or proxy settings have to be applied to the transport
+func (self *options) customTls() bool {
+	return self.insecureSkipVerify || self.rootCAs != nil || len(self.certificates) > 0 || self.proxy != nil
+}
+
+// Option configures a Client created by NewWithOptions
+type Option func(*options) error
+
+// WithHttpClient sends requests with httpClient. The client is copied, so timeout, TLS and
+// proxy options do not modify it.
+func WithHttpClient(httpClient *http.Client) Option {
+	return func(o *options) error {
+		if httpClient == nil {
+			return errors.New("http: nil http client")
+		}
+		o.httpClient = httpClient
+		return nil
+	}
+}
+
+// WithTransport sends requests with transport. TLS and proxy options can only be combined
+// with an *http.Transport, which is cloned before they are applied.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(o *options) error {
+		if transport == nil {
+			return errors.New("http: nil transport")
+		}
+		o.transport = transport
+		return nil
+	}
+}
+
+// WithTimeout limits the total time of a single request including reading the response
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) error {
+		o.timeout = &timeout
+		return nil
+	}
+}
+
+func WithInsecureSkipVerify(insecureSkipVerify bool) Option {
+	return func(o *options) error {
+		o.insecureSkipVerify = insecureSkipVerify
+		return nil
+	}
+}
+
+// WithCaBundle trusts the PEM encoded certificates instead of the system roots
+func WithCaBundle(pemCerts []byte) Option {
+	return func(o *options) error {
+		if o.rootCAs == nil {
+			o.rootCAs = x509.NewCertPool()
+		}
+		if !o.rootCAs.AppendCertsFromPEM(pemCerts) {
+			return errors.New("http: no certificates found in CA bundle")
+		}
+		return nil
+	}
+}
+func WithCaBundleFile(path string) Option {
+	return func(o *options) error {
+		pemCerts, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return WithCaBundle(pemCerts)(o)
+	}
+}
+
+// WithClientCertificate presents the certificate to the server (mutual TLS)
+func WithClientCertificate(certificate tls.Certificate) Option {
+	return func(o *options) error {
+		o.certificates = append(o.certificates, certificate)
+		return nil
+	}
+}
+func WithClientCertificateFile(certFile, keyFile string) Option {
+	return func(o *options) error {
+		certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return err
+		}
+		o.certificates = append(o.certificates, certificate)
+		return nil
+	}
+}
+
+// WithProxy sends all requests through proxyUrl
+func WithProxy(proxyUrl *url.URL) Option {
+	return WithProxyFunc(http.ProxyURL(proxyUrl))
+}
+
+// WithProxyFunc selects the proxy per request, http.ProxyFromEnvironment can be used
+func WithProxyFunc(proxy func(*http.Request) (*url.URL, error)) Option {
+	return func(o *options) error {
+		o.proxy = proxy
+		return nil
+	}
+}
+
+func WithUserAgent(userAgent string) Option {
+	return func(o *options) error {
+		o.userAgent = userAgent
+		return nil
+	}
+}
+
+// WithHeader adds a header sent with every request
+func WithHeader(name, value string) Option {
+	return func(o *options) error {
+		if o.headers == nil {
+			o.headers = http.Header{}
+		}
+		o.headers.Add(name, value)
+		return nil
+	}
+}
+
+func WithAuthenticator(authenticator Authenticator) Option {
+	return func(o *options) error {
+		o.authenticator = authenticator
+		return nil
+	}
+}
+
+func WithRetryPolicy(retryPolicy *RetryPolicy) Option {
+	return func(o *options) error {
+		o.retryPolicy = retryPolicy
+		return nil
+	}
+}
+
+// WithGzip compresses insert and command payloads of at least minSize bytes, they are sent
+// with Content-Encoding: gzip. Small payloads are sent as is, since compression would not pay off.
+func WithGzip(minSize int) Option {
+	return func(o *options) error {
+		if minSize < 0 {
+			return errors.New("http: negative gzip threshold")
+		}
+		o.gzipMinSize = &minSize
+		return nil
+	}
+}
+
+// WithChunking splits series inserts and queries exceeding the limits of config into several requests
+func WithChunking(config ChunkConfig) Option {
+	return func(o *options) error {
+		if config.MaxSamples < 0 || config.MaxQueries < 0 || config.Parallelism < 0 {
+			return errors.New("http: negative chunk limits")
+		}
+		o.chunking = config
+		return nil
+	}
+}
+
+func (self *options) buildHttpClient() (*http.Client, error) {
+	var httpClient http.Client
+	if self.httpClient != nil {
+		httpClient = *self.httpClient
+	}
+	if self.transport != nil {
+		httpClient.Transport = self.transport
+	}
+	if httpClient.Transport == nil {
+		httpClient.Transport = http.DefaultTransport
+	}
+	if self.customTls() {
+		transport, ok := httpClient.Transport.(*http.Transport)
+		if !ok {
+			return nil, errors.New("http: TLS and proxy options require an *http.Transport")
+		}
+		transport = transport.Clone()
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		if self.insecureSkipVerify {
+			transport.TLSClientConfig.InsecureSkipVerify = true
+		}
+		if self.rootCAs != nil {
+			transport.TLSClientConfig.RootCAs = self.rootCAs
+		}
+		if len(self.certificates) > 0 {
+			transport.TLSClientConfig.Certificates = append(transport.TLSClientConfig.Certificates, self.certificates...)
+		}
+		if self.proxy != nil {
+			transport.Proxy = self.proxy
+		}
+		httpClient.Transport = transport
+	}
+	if self.timeout != nil {
+		httpClient.Timeout = *self.timeout
+	}
+	return &httpClient, nil
+}
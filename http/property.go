@@ -16,8 +16,11 @@
 package http
 
 import (
+	"bytes"
 	"encoding/json"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/axibase/atsd-api-go/net"
 )
@@ -94,7 +97,96 @@ func (self *Property) MarshalJSON() ([]byte, error) {
 
 	return json.Marshal(m)
 }
+func (self *Property) UnmarshalJSON(data []byte) error {
+	var jsonMap map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&jsonMap); err != nil {
+		return err
+	}
+	self.propType = ""
+	if iType, ok := jsonMap["type"]; ok {
+		propType, ok := iType.(string)
+		if !ok {
+			return &ValueError{Field: "type", Value: iType}
+		}
+		self.propType = propType
+	}
+	self.entity = ""
+	if iEntity, ok := jsonMap["entity"]; ok {
+		entity, ok := iEntity.(string)
+		if !ok {
+			return &ValueError{Field: "entity", Value: iEntity}
+		}
+		self.entity = entity
+	}
+	self.key = map[string]string{}
+	self.tags = map[string]string{}
+	self.timestamp = nil
+	if iTimestamp, ok := jsonMap["timestamp"]; ok {
+		timestamp, ok := iTimestamp.(json.Number)
+		if !ok {
+			return &ValueError{Field: "timestamp", Value: iTimestamp}
+		}
+		t, err := timestamp.Int64()
+		if err != nil {
+			return &ValueError{Field: "timestamp", Value: iTimestamp}
+		}
+		self.SetTimestamp(net.Millis(t))
+	} else if iDate, ok := jsonMap["date"]; ok {
+		date, ok := iDate.(string)
+		if !ok {
+			return &ValueError{Field: "date", Value: iDate}
+		}
+		t, err := time.Parse(time.RFC3339Nano, date)
+		if err != nil {
+			return &ValueError{Field: "date", Value: iDate}
+		}
+		self.SetTimestamp(net.Millis(t.UnixNano() / 1e6))
+	}
+	if err := decodeStringMap(jsonMap, "key", self.key); err != nil {
+		return err
+	}
+	if err := decodeStringMap(jsonMap, "tags", self.tags); err != nil {
+		return err
+	}
+	return nil
+}
 func (self *Property) String() string {
 	obj, _ := self.MarshalJSON()
 	return string(obj)
 }
+
+// keyString formats the key with sorted names, so equal keys have equal strings
+func keyString(key map[string]string) string {
+	names := make([]string, 0, len(key))
+	for name := range key {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, name+"="+key[name])
+	}
+	return strings.Join(parts, ";")
+}
+
+// decodeStringMap copies the string values of the object field into m
+func decodeStringMap(jsonMap map[string]interface{}, field string, m map[string]string) error {
+	iObject, ok := jsonMap[field]
+	if !ok {
+		return nil
+	}
+	object, ok := iObject.(map[string]interface{})
+	if !ok {
+		return &ValueError{Field: field, Value: iObject}
+	}
+	for name, val := range object {
+		str, ok := val.(string)
+		if !ok {
+			return &ValueError{Field: field + "." + name, Value: val}
+		}
+		m[name] = str
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+/*
+* Copyright 2015 Axibase Corporation or its affiliates. All Rights Reserved.
+*
+* Licensed under the Apache License, Version 2.0 (the "License").
+* You may not use this file except in compliance with the License.
+* A copy of the License is located at
+*
+* https://www.axibase.com/atsd/axibase-apache-2.0.pdf
+*
+* or in the "license" file accompanying this file. This file is distributed
+* on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+* express or implied. See the License for the specific language governing
+* permissions and limitations under the License.
+ */
+
+package http
+
+import (
+	"github.com/axibase/atsd-api-go/net"
+)
+
+type PropertiesQuery struct {
+	Type             string            `json:"type"`
+	Entity           string            `json:"entity,omitempty"`
+	Entities         []string          `json:"entities,omitempty"`
+	EntityGroup      string            `json:"entityGroup,omitempty"`
+	EntityExpression string            `json:"entityExpression,omitempty"`
+	Key              map[string]string `json:"key,omitempty"`
+	KeyTagExpression string            `json:"keyTagExpression,omitempty"`
+	StartTime        net.Millis        `json:"startTime,omitempty"`
+	EndTime          net.Millis        `json:"endTime,omitempty"`
+	StartDate        string            `json:"startDate,omitempty"`
+	EndDate          string            `json:"endDate,omitempty"`
+	Interval         *Period           `json:"interval,omitempty"`
+	ExactMatch       bool              `json:"exactMatch,omitempty"` //match properties with exactly the same key instead of a key prefix
+	Last             bool              `json:"last,omitempty"`       //return only properties with the latest timestamp
+	Limit            uint64            `json:"limit,omitempty"`
+}
+
+type PropertiesDeleteQuery struct {
+	Type       string            `json:"type"`
+	Entity     string            `json:"entity"`
+	Key        map[string]string `json:"key,omitempty"`
+	ExactMatch bool              `json:"exactMatch,omitempty"`
+	StartTime  net.Millis        `json:"startTime,omitempty"`
+	EndTime    net.Millis        `json:"endTime,omitempty"`
+	StartDate  string            `json:"startDate,omitempty"`
+	EndDate    string            `json:"endDate,omitempty"`
+}
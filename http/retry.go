@@ -0,0 +1,139 @@
+/*
+* Copyright 2015 Axibase Corporation or its affiliates. All Rights Reserved.
+*
+* Licensed under the Apache License, Version 2.0 (the "License").
+* You may not use this file except in compliance with the License.
+* A copy of the License is located at
+*
+* https://www.axibase.com/atsd/axibase-apache-2.0.pdf
+*
+* or in the "license" file accompanying this file. This file is distributed
+* on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+* express or implied. See the License for the specific language governing
+* permissions and limitations under the License.
+ */
+
+package http
+
+import (
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// RetryAttempt describes a finished attempt, it is passed to RetryPolicy.OnAttempt
+type RetryAttempt struct {
+	Attempt int //1-based attempt number
+	Method  string
+	Path    string
+	Err     error         //nil if the attempt succeeded
+	Delay   time.Duration //delay before the next attempt, 0 if there is none
+}
+
+// RetryPolicy controls how failed requests are repeated. Queries are retried on
+// connection failures and retryable status codes, inserts only if RetryInserts is set.
+type RetryPolicy struct {
+	MaxAttempts     int //total number of attempts including the first one
+	InitialBackoff  time.Duration
+	MaxBackoff      time.Duration
+	Multiplier      float64
+	Jitter          float64 //fraction of the backoff randomly added or subtracted, 0..1
+	RetryableStatus map[int]bool
+	RetryInserts    bool
+	OnAttempt       func(attempt RetryAttempt)
+}
+
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     30 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+		RetryableStatus: map[int]bool{
+			http.StatusRequestTimeout:      true,
+			http.StatusTooManyRequests:     true,
+			http.StatusInternalServerError: true,
+			http.StatusBadGateway:          true,
+			http.StatusServiceUnavailable:  true,
+			http.StatusGatewayTimeout:      true,
+		},
+	}
+}
+
+func (self *RetryPolicy) shouldRetry(err error) bool {
+	var apiError *APIError
+	if errors.As(err, &apiError) {
+		return self.RetryableStatus[apiError.StatusCode]
+	}
+	if isConnectionError(err) {
+		return true
+	}
+	var netError net.Error
+	return errors.As(err, &netError) && netError.Timeout()
+}
+
+// isConnectionError reports whether the connection was refused or broken
+func isConnectionError(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE)
+}
+
+// backoff returns the delay after the given failed attempt, Retry-After sent by the server takes precedence.
+// Both are limited by MaxBackoff if it is set.
+func (self *RetryPolicy) backoff(attempt int, err error) time.Duration {
+	var apiError *APIError
+	if errors.As(err, &apiError) {
+		if delay, ok := parseRetryAfter(apiError.Header.Get("Retry-After")); ok {
+			if self.MaxBackoff > 0 && delay > self.MaxBackoff {
+				delay = self.MaxBackoff
+			}
+			return delay
+		}
+	}
+	multiplier := self.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	delay := float64(self.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if self.MaxBackoff > 0 && delay > float64(self.MaxBackoff) {
+		delay = float64(self.MaxBackoff)
+	}
+	if self.Jitter > 0 {
+		delay += delay * self.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(delay)
+}
+
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
+
+// isInsert reports whether the request stores data and may duplicate it if repeated
+func isInsert(reqType, apiUrl string) bool {
+	path := apiUrl
+	if i := strings.Index(path, "?"); i >= 0 {
+		path = path[:i]
+	}
+	return reqType == "POST" && (strings.HasSuffix(path, "/insert") || path == commandPath)
+}
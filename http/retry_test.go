@@ -0,0 +1,192 @@
+/*
+* Copyright 2015 Axibase Corporation or its affiliates. All Rights Reserved.
+*
+* Licensed under the Apache License, Version 2.0 (the "License").
+* You may not use this file except in compliance with the License.
+* A copy of the License is located at
+*
+* https://www.axibase.com/atsd/axibase-apache-2.0.pdf
+*
+* or in the "license" file accompanying this file. This file is distributed
+* on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+* express or implied. See the License for the specific language governing
+* permissions and limitations under the License.
+ */
+
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestClient starts a server which answers the n-th request (1-based) with handler
+func newTestClient(t *testing.T, policy *RetryPolicy, handler func(n int32, w http.ResponseWriter, r *http.Request)) (*Client, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler(atomic.AddInt32(&requests, 1), w, r)
+	}))
+	t.Cleanup(server.Close)
+	serverUrl, _ := url.Parse(server.URL)
+	client, err := NewWithOptions(*serverUrl, WithRetryPolicy(policy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, &requests
+}
+
+func testRetryPolicy() *RetryPolicy {
+	policy := DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	policy.Jitter = 0
+	return policy
+}
+
+func unavailableUntil(attempt int32) func(n int32, w http.ResponseWriter, r *http.Request) {
+	return func(n int32, w http.ResponseWriter, r *http.Request) {
+		if n < attempt {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"series":[]}`))
+	}
+}
+
+func TestRetryQueryAfterServiceUnavailable(t *testing.T) {
+	client, requests := newTestClient(t, testRetryPolicy(), unavailableUntil(2))
+	if _, err := client.Series.Query([]*SeriesQuery{{Entity: "e", Metric: "m"}}); err != nil {
+		t.Fatal(err)
+	}
+	if *requests != 2 {
+		t.Errorf("expected 2 requests, got %v", *requests)
+	}
+}
+
+func TestRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	client, requests := newTestClient(t, testRetryPolicy(), unavailableUntil(100))
+	_, err := client.Series.Query([]*SeriesQuery{{Entity: "e", Metric: "m"}})
+	var apiError *APIError
+	if !errors.As(err, &apiError) || apiError.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 APIError, got %v", err)
+	}
+	if *requests != 3 {
+		t.Errorf("expected 3 requests, got %v", *requests)
+	}
+}
+
+func TestRetryAfterHeader(t *testing.T) {
+	policy := testRetryPolicy()
+	var delays []time.Duration
+	policy.OnAttempt = func(attempt RetryAttempt) {
+		delays = append(delays, attempt.Delay)
+	}
+	client, _ := newTestClient(t, policy, func(n int32, w http.ResponseWriter, r *http.Request) {
+		if n == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"series":[]}`))
+	})
+	start := time.Now()
+	if _, err := client.Series.Query([]*SeriesQuery{{Entity: "e", Metric: "m"}}); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %v, expected to wait for Retry-After", elapsed)
+	}
+	if len(delays) != 2 || delays[0] != time.Second {
+		t.Errorf("expected delay of 1s before the second attempt, got %v", delays)
+	}
+}
+
+func TestRetryAfterCappedByMaxBackoff(t *testing.T) {
+	policy := testRetryPolicy()
+	policy.MaxBackoff = 10 * time.Millisecond
+	client, _ := newTestClient(t, policy, func(n int32, w http.ResponseWriter, r *http.Request) {
+		if n == 1 {
+			w.Header().Set("Retry-After", "86400")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"series":[]}`))
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := client.Series.QueryContext(ctx, []*SeriesQuery{{Entity: "e", Metric: "m"}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRetryInserts(t *testing.T) {
+	series := []*Series{{Entity: "e", Metric: "m"}}
+
+	client, requests := newTestClient(t, testRetryPolicy(), unavailableUntil(2))
+	if err := client.Series.Insert(series); err == nil {
+		t.Error("expected the insert to fail without retries")
+	}
+	if *requests != 1 {
+		t.Errorf("expected 1 request without RetryInserts, got %v", *requests)
+	}
+
+	policy := testRetryPolicy()
+	policy.RetryInserts = true
+	client, requests = newTestClient(t, policy, unavailableUntil(2))
+	if err := client.Series.Insert(series); err != nil {
+		t.Fatal(err)
+	}
+	if *requests != 2 {
+		t.Errorf("expected 2 requests with RetryInserts, got %v", *requests)
+	}
+}
+
+func TestRetryOnAttempt(t *testing.T) {
+	policy := testRetryPolicy()
+	policy.MaxAttempts = 5
+	var attempts []RetryAttempt
+	policy.OnAttempt = func(attempt RetryAttempt) {
+		attempts = append(attempts, attempt)
+	}
+	client, requests := newTestClient(t, policy, unavailableUntil(3))
+	if _, err := client.Series.Query([]*SeriesQuery{{Entity: "e", Metric: "m"}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(attempts) != int(*requests) || len(attempts) != 3 {
+		t.Fatalf("expected 3 attempts for %v requests, got %+v", *requests, attempts)
+	}
+	for i, attempt := range attempts {
+		if attempt.Attempt != i+1 || attempt.Method != "POST" || attempt.Path != seriesQueryPath {
+			t.Errorf("unexpected attempt %+v", attempt)
+		}
+		last := i == len(attempts)-1
+		if last != (attempt.Err == nil) || last != (attempt.Delay == 0) {
+			t.Errorf("attempt %v: err %v, delay %v", attempt.Attempt, attempt.Err, attempt.Delay)
+		}
+	}
+}
+
+func TestRetryCanceledDuringBackoff(t *testing.T) {
+	policy := testRetryPolicy()
+	policy.InitialBackoff = time.Minute
+	client, requests := newTestClient(t, policy, unavailableUntil(100))
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := client.Series.QueryContext(ctx, []*SeriesQuery{{Entity: "e", Metric: "m"}})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("cancellation took %v", elapsed)
+	}
+	if *requests != 1 {
+		t.Errorf("expected 1 request, got %v", *requests)
+	}
+}
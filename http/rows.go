@@ -0,0 +1,315 @@
+/*
+* Copyright 2015 Axibase Corporation or its affiliates. All Rights Reserved.
+*
+* Licensed under the Apache License, Version 2.0 (the "License").
+* You may not use this file except in compliance with the License.
+* A copy of the License is located at
+*
+* https://www.axibase.com/atsd/axibase-apache-2.0.pdf
+*
+* or in the "license" file accompanying this file. This file is distributed
+* on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+* express or implied. See the License for the specific language governing
+* permissions and limitations under the License.
+ */
+
+package http
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/axibase/atsd-api-go/net"
+)
+
+type OutputFormat string
+
+const (
+	JsonFormat OutputFormat = "json"
+	CsvFormat  OutputFormat = "csv"
+)
+
+// Rows iterates over the result of an SQL query, decoding one row at a time from the response body.
+// Rows must be closed when it is no longer needed.
+//
+//	rows, err := client.SQL.QueryRows(query, http.JsonFormat)
+//	...
+//	defer rows.Close()
+//	for rows.Next() {
+//		var entity string
+//		var value float64
+//		if err := rows.Scan(&entity, &value); err != nil {
+//			...
+//		}
+//	}
+//	err = rows.Err()
+type Rows struct {
+	body io.ReadCloser
+
+	dec *json.Decoder
+	csv *csv.Reader
+
+	metadata *TableMetadata
+	columns  []string
+	row      []interface{}
+	err      error
+	done     bool
+}
+
+func newJsonRows(body io.ReadCloser) (*Rows, error) {
+	rows := &Rows{body: body, dec: json.NewDecoder(body)}
+	rows.dec.UseNumber()
+	if err := rows.readHeader(); err != nil {
+		body.Close()
+		return nil, &DecodeError{Err: err}
+	}
+	return rows, nil
+}
+
+func newCsvRows(body io.ReadCloser) (*Rows, error) {
+	rows := &Rows{body: body, csv: csv.NewReader(body)}
+	rows.csv.ReuseRecord = true
+	header, err := rows.csv.Read()
+	if err == io.EOF {
+		rows.done = true
+		return rows, nil
+	}
+	if err != nil {
+		body.Close()
+		return nil, &DecodeError{Err: err}
+	}
+	rows.columns = append([]string{}, header...)
+	return rows, nil
+}
+
+// readHeader reads the JSON document up to the first row of the "data" array,
+// metadata which precedes the data is decoded on the way
+func (self *Rows) readHeader() error {
+	if err := self.expectDelim('{'); err != nil {
+		return err
+	}
+	for self.dec.More() {
+		token, err := self.dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := token.(string)
+		switch key {
+		case "data":
+			return self.expectDelim('[')
+		case "metadata":
+			if err := self.dec.Decode(&self.metadata); err != nil {
+				return err
+			}
+			self.columns = self.metadata.ColumnNames()
+		default:
+			var skip json.RawMessage
+			if err := self.dec.Decode(&skip); err != nil {
+				return err
+			}
+		}
+	}
+	self.done = true
+	return nil
+}
+
+func (self *Rows) expectDelim(delim json.Delim) error {
+	token, err := self.dec.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("expected %v, got %v", delim, token)
+	}
+	return nil
+}
+
+// Metadata returns the metadata of the result, nil for CSV
+func (self *Rows) Metadata() *TableMetadata {
+	return self.metadata
+}
+
+// Columns returns the column names, taken from metadata for JSON and from the header for CSV
+func (self *Rows) Columns() []string {
+	return self.columns
+}
+
+// Next decodes the next row, it returns false when there are no more rows or an error occurred
+func (self *Rows) Next() bool {
+	if self.done || self.err != nil {
+		return false
+	}
+	if self.csv != nil {
+		return self.nextCsv()
+	}
+	return self.nextJson()
+}
+
+func (self *Rows) nextJson() bool {
+	if !self.dec.More() {
+		self.done = true
+		return false
+	}
+	self.row = self.row[:0]
+	if err := self.dec.Decode(&self.row); err != nil {
+		self.err = &DecodeError{Err: err}
+		return false
+	}
+	return true
+}
+
+func (self *Rows) nextCsv() bool {
+	record, err := self.csv.Read()
+	if err == io.EOF {
+		self.done = true
+		return false
+	}
+	if err != nil {
+		self.err = &DecodeError{Err: err}
+		return false
+	}
+	self.row = self.row[:0]
+	for _, field := range record {
+		self.row = append(self.row, field)
+	}
+	return true
+}
+
+// Values returns the cells of the current row, the slice is reused by Next
+func (self *Rows) Values() []interface{} {
+	return self.row
+}
+
+// TypedValues returns the cells of the current row converted to the column types, see TableColumn.Convert
+func (self *Rows) TypedValues() ([]interface{}, error) {
+	return convertRow(self.metadata, self.row)
+}
+
+// Scan copies the cells of the current row into dest. Supported destinations are
+// *interface{}, *string, *json.Number, *int64, *int, *float64, *bool, *time.Time and *net.Number.
+func (self *Rows) Scan(dest ...interface{}) error {
+	if self.row == nil {
+		return errors.New("http: Scan called without calling Next")
+	}
+	if len(dest) != len(self.row) {
+		return fmt.Errorf("http: expected %v destination arguments in Scan, not %v", len(self.row), len(dest))
+	}
+	for i, value := range self.row {
+		if err := assignCell(dest[i], value); err != nil {
+			return fmt.Errorf("http: Scan column %v: %v", i, err)
+		}
+	}
+	return nil
+}
+
+func (self *Rows) Err() error {
+	return self.err
+}
+
+func (self *Rows) Close() error {
+	self.done = true
+	return self.body.Close()
+}
+
+func assignCell(dest, value interface{}) error {
+	switch d := dest.(type) {
+	case *time.Time:
+		if value == nil {
+			*d = time.Time{}
+			return nil
+		}
+		t, err := cellTime(value)
+		*d = t
+		return err
+	case *net.Number:
+		if value == nil {
+			*d = nil
+			return nil
+		}
+		n, err := cellNumber(value, false)
+		*d = n
+		return err
+	case *interface{}:
+		*d = value
+		return nil
+	case *string:
+		switch v := value.(type) {
+		case nil:
+			*d = ""
+		case string:
+			*d = v
+		case json.Number:
+			*d = v.String()
+		default:
+			*d = fmt.Sprint(v)
+		}
+		return nil
+	case *json.Number:
+		switch v := value.(type) {
+		case nil:
+			*d = ""
+		case json.Number:
+			*d = v
+		case string:
+			*d = json.Number(v)
+		default:
+			return fmt.Errorf("cannot convert %#v to json.Number", value)
+		}
+		return nil
+	case *int64:
+		n, err := cellInt(value)
+		*d = n
+		return err
+	case *int:
+		n, err := cellInt(value)
+		*d = int(n)
+		return err
+	case *float64:
+		switch v := value.(type) {
+		case nil:
+			*d = 0
+			return nil
+		case json.Number:
+			f, err := v.Float64()
+			*d = f
+			return err
+		case string:
+			f, err := strconv.ParseFloat(v, 64)
+			*d = f
+			return err
+		}
+	case *bool:
+		switch v := value.(type) {
+		case nil:
+			*d = false
+			return nil
+		case bool:
+			*d = v
+			return nil
+		case string:
+			b, err := strconv.ParseBool(v)
+			*d = b
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported destination type %T", dest)
+	}
+	return fmt.Errorf("cannot convert %#v to %T", value, dest)
+}
+
+func cellInt(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case nil:
+		return 0, nil
+	case json.Number:
+		return v.Int64()
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	}
+	return 0, fmt.Errorf("cannot convert %#v to integer", value)
+}
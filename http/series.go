@@ -18,6 +18,7 @@ package http
 import (
 	"bytes"
 	"encoding/json"
+	"strconv"
 	"strings"
 	"time"
 
@@ -36,23 +37,45 @@ func (self *Sample) UnmarshalJSON(data []byte) error {
 	if err := dec.Decode(&jsonMap); err != nil {
 		return err
 	}
-	self.T, _ = jsonMap["t"].(net.Millis)
+	switch t := jsonMap["t"].(type) {
+	case json.Number:
+		millis, err := strconv.ParseUint(t.String(), 10, 64)
+		if err != nil {
+			return &ValueError{Field: "t", Value: t}
+		}
+		self.T = net.Millis(millis)
+	case nil:
+	default:
+		return &ValueError{Field: "t", Value: t}
+	}
 	switch value := jsonMap["v"].(type) {
 	case json.Number:
-		strRep := value.String()
-		if strings.Contains(strRep, ".") {
-			temp, _ := value.Float64()
-			self.V = net.Float64(temp)
-		} else {
-			temp, _ := value.Int64()
-			self.V = net.Int64(temp)
+		number, err := parseNumber(value)
+		if err != nil {
+			return &ValueError{Field: "v", Value: value}
 		}
+		self.V = number
 	default:
-		panic(value)
+		return &ValueError{Field: "v", Value: value}
 	}
 	return nil
 }
 
+// parseNumber converts a JSON number to net.Int64 if it is an integer within the int64 range,
+// to net.Float64 otherwise
+func parseNumber(value json.Number) (net.Number, error) {
+	if !strings.ContainsAny(value.String(), ".eE") {
+		if temp, err := value.Int64(); err == nil {
+			return net.Int64(temp), nil
+		}
+	}
+	temp, err := value.Float64()
+	if err != nil {
+		return nil, err
+	}
+	return net.Float64(temp), nil
+}
+
 type ForecastMeta struct {
 	Timestamp         net.Millis    `json:"timestamp"`
 	AveragingInterval time.Duration `json:"averagingInterval"`
@@ -76,3 +99,25 @@ type Series struct {
 	Meta         *ForecastMeta `json:"meta,omitempty"`
 	Aggregate    *Aggregation  `json:"aggregate,omitempty"`
 }
+
+// SeriesDescriptor identifies a series without its samples
+type SeriesDescriptor struct {
+	Metric         string            `json:"metric"`
+	Entity         string            `json:"entity"`
+	Tags           map[string]string `json:"tags"`
+	LastInsertDate *time.Time        `json:"lastInsertDate,omitempty"`
+}
+
+// TimeRange is a half-open interval [Start, End), zero Start or End leaves the interval unbounded
+type TimeRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+type seriesDeleteQuery struct {
+	Entity    string            `json:"entity"`
+	Metric    string            `json:"metric"`
+	Tags      map[string]string `json:"tags,omitempty"`
+	StartDate string            `json:"startDate,omitempty"`
+	EndDate   string            `json:"endDate,omitempty"`
+}
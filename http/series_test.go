@@ -0,0 +1,50 @@
+/*
+* Copyright 2015 Axibase Corporation or its affiliates. All Rights Reserved.
+*
+* Licensed under the Apache License, Version 2.0 (the "License").
+* You may not use this file except in compliance with the License.
+* A copy of the License is located at
+*
+* https://www.axibase.com/atsd/axibase-apache-2.0.pdf
+*
+* or in the "license" file accompanying this file. This file is distributed
+* on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+* express or implied. See the License for the specific language governing
+* permissions and limitations under the License.
+ */
+
+package http
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/axibase/atsd-api-go/net"
+)
+
+func TestSampleUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		data     string
+		expected Sample
+		err      bool
+	}{
+		{`{"t":1700000000000,"v":12}`, Sample{T: 1700000000000, V: net.Int64(12)}, false},
+		{`{"t":1,"v":1.5}`, Sample{T: 1, V: net.Float64(1.5)}, false},
+		{`{"t":1,"v":-9223372036854775808}`, Sample{T: 1, V: net.Int64(-9223372036854775808)}, false},
+		{`{"t":1,"v":18446744073709551615}`, Sample{T: 1, V: net.Float64(18446744073709551615)}, false},
+		{`{"t":1,"v":1e999}`, Sample{}, true},
+		{`{"t":"1","v":1}`, Sample{}, true},
+		{`{"t":1,"v":"1"}`, Sample{}, true},
+	}
+	for _, test := range tests {
+		var sample Sample
+		err := json.Unmarshal([]byte(test.data), &sample)
+		if (err != nil) != test.err {
+			t.Errorf("%v: unexpected error %v", test.data, err)
+			continue
+		}
+		if !test.err && sample != test.expected {
+			t.Errorf("%v: got %#v, expected %#v", test.data, sample, test.expected)
+		}
+	}
+}
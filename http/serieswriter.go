@@ -0,0 +1,235 @@
+/*
+* Copyright 2015 Axibase Corporation or its affiliates. All Rights Reserved.
+*
+* Licensed under the Apache License, Version 2.0 (the "License").
+* You may not use this file except in compliance with the License.
+* A copy of the License is located at
+*
+* https://www.axibase.com/atsd/axibase-apache-2.0.pdf
+*
+* or in the "license" file accompanying this file. This file is distributed
+* on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+* express or implied. See the License for the specific language governing
+* permissions and limitations under the License.
+ */
+
+package http
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+var ErrWriterClosed = errors.New("http: series writer is closed")
+
+type SeriesWriterConfig struct {
+	BatchSize   int           //maximum number of samples sent in one request, 1000 by default
+	MaxLatency  time.Duration //maximum time a sample waits for its batch to fill, 1 second by default
+	Concurrency int           //maximum number of requests in flight, 2 by default
+	QueueSize   int           //number of appended series buffered before Append blocks, 10 * BatchSize by default
+	ErrorsSize  int           //capacity of the Errors channel, errors are logged and dropped when it is full, 100 by default
+}
+
+// BatchError reports a batch which could not be inserted
+type BatchError struct {
+	Series []*Series
+	Err    error
+}
+
+func (self *BatchError) Error() string {
+	return self.Err.Error()
+}
+func (self *BatchError) Unwrap() error {
+	return self.Err
+}
+
+// SeriesWriter inserts series asynchronously. Appended samples are merged by series and sent in
+// batches when BatchSize samples are collected or MaxLatency passes. Append blocks when QueueSize
+// series are waiting, so producers slow down to the rate the server accepts.
+type SeriesWriter struct {
+	client *Client
+	config SeriesWriterConfig
+
+	input   chan *Series
+	batches chan []*Series
+	errors  chan *BatchError
+
+	mutex     sync.RWMutex
+	closed    bool
+	collector sync.WaitGroup
+	senders   sync.WaitGroup
+}
+
+func NewSeriesWriter(client *Client, config SeriesWriterConfig) *SeriesWriter {
+	if config.BatchSize <= 0 {
+		config.BatchSize = 1000
+	}
+	if config.MaxLatency <= 0 {
+		config.MaxLatency = time.Second
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = 2
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = 10 * config.BatchSize
+	}
+	if config.ErrorsSize <= 0 {
+		config.ErrorsSize = 100
+	}
+	writer := &SeriesWriter{
+		client:  client,
+		config:  config,
+		input:   make(chan *Series, config.QueueSize),
+		batches: make(chan []*Series),
+		errors:  make(chan *BatchError, config.ErrorsSize),
+	}
+	writer.collector.Add(1)
+	go writer.collect()
+	writer.senders.Add(config.Concurrency)
+	for i := 0; i < config.Concurrency; i++ {
+		go writer.send()
+	}
+	return writer
+}
+
+// Errors returns the channel of failed batches, it is closed by Close
+func (self *SeriesWriter) Errors() <-chan *BatchError {
+	return self.errors
+}
+
+func (self *SeriesWriter) Append(series *Series) error {
+	return self.AppendContext(context.Background(), series)
+}
+
+// AppendContext queues the series, waiting for free space until ctx is done
+func (self *SeriesWriter) AppendContext(ctx context.Context, series *Series) error {
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+	if self.closed {
+		return ErrWriterClosed
+	}
+	if series == nil {
+		return nil
+	}
+	select {
+	case self.input <- series:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (self *SeriesWriter) AppendSample(entity, metric string, tags map[string]string, sample *Sample) error {
+	return self.Append(&Series{Entity: entity, Metric: metric, Tags: tags, Data: []*Sample{sample}})
+}
+
+// Close sends the queued series and waits for all requests to finish
+func (self *SeriesWriter) Close() error {
+	self.mutex.Lock()
+	if self.closed {
+		self.mutex.Unlock()
+		return nil
+	}
+	self.closed = true
+	close(self.input)
+	self.mutex.Unlock()
+
+	self.collector.Wait()
+	self.senders.Wait()
+	close(self.errors)
+	return nil
+}
+
+// collect merges appended series into batches
+func (self *SeriesWriter) collect() {
+	defer self.collector.Done()
+	defer close(self.batches)
+
+	batch := newSeriesBatch()
+	timer := time.NewTimer(self.config.MaxLatency)
+	defer timer.Stop()
+	flush := func() {
+		if batch.samples > 0 {
+			self.batches <- batch.list
+			batch = newSeriesBatch()
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(self.config.MaxLatency)
+	}
+	for {
+		select {
+		case series, ok := <-self.input:
+			if !ok {
+				flush()
+				return
+			}
+			batch.add(series)
+			if batch.samples >= self.config.BatchSize {
+				flush()
+			}
+		case <-timer.C:
+			flush()
+		}
+	}
+}
+
+func (self *SeriesWriter) send() {
+	defer self.senders.Done()
+	for batch := range self.batches {
+		if err := self.client.Series.Insert(batch); err != nil {
+			self.report(&BatchError{Series: batch, Err: err})
+		}
+	}
+}
+
+func (self *SeriesWriter) report(batchError *BatchError) {
+	select {
+	case self.errors <- batchError:
+	default:
+		glog.Errorf("series writer: dropped error of a batch of %v series: %v", len(batchError.Series), batchError.Err)
+	}
+}
+
+// seriesBatch merges samples of the same series, series with forecast meta or aggregation
+// are sent as appended
+type seriesBatch struct {
+	list    []*Series
+	index   map[string]*Series
+	samples int
+}
+
+func newSeriesBatch() *seriesBatch {
+	return &seriesBatch{index: map[string]*Series{}}
+}
+
+func (self *seriesBatch) add(series *Series) {
+	self.samples += len(series.Data)
+	if series.Meta != nil || series.Aggregate != nil {
+		self.list = append(self.list, series)
+		return
+	}
+	key := series.Entity + "\x00" + series.Metric + "\x00" + keyString(series.Tags) + "\x00" +
+		string(series.Type) + "\x00" + series.ForecastName
+	merged, ok := self.index[key]
+	if !ok {
+		merged = &Series{
+			Entity:       series.Entity,
+			Metric:       series.Metric,
+			Tags:         series.Tags,
+			Type:         series.Type,
+			ForecastName: series.ForecastName,
+		}
+		self.index[key] = merged
+		self.list = append(self.list, merged)
+	}
+	merged.Data = append(merged.Data, series.Data...)
+}
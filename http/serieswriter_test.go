@@ -0,0 +1,222 @@
+/*
+* Copyright 2015 Axibase Corporation or its affiliates. All Rights Reserved.
+*
+* Licensed under the Apache License, Version 2.0 (the "License").
+* You may not use this file except in compliance with the License.
+* A copy of the License is located at
+*
+* https://www.axibase.com/atsd/axibase-apache-2.0.pdf
+*
+* or in the "license" file accompanying this file. This file is distributed
+* on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+* express or implied. See the License for the specific language governing
+* permissions and limitations under the License.
+ */
+
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/axibase/atsd-api-go/net"
+)
+
+// seriesServer records the batches inserted by the writer
+type seriesServer struct {
+	mutex   sync.Mutex
+	batches [][]*Series
+}
+
+func newSeriesServer(t *testing.T, delay time.Duration) (*Client, *seriesServer) {
+	server := &seriesServer{}
+	client, _ := newTestClient(t, testRetryPolicy(), func(n int32, w http.ResponseWriter, r *http.Request) {
+		var batch []*Series
+		if r.URL.Path != seriesInsertPath || json.NewDecoder(r.Body).Decode(&batch) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		time.Sleep(delay)
+		server.mutex.Lock()
+		server.batches = append(server.batches, batch)
+		server.mutex.Unlock()
+	})
+	return client, server
+}
+
+func (self *seriesServer) received() [][]*Series {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return append([][]*Series(nil), self.batches...)
+}
+
+// waitBatches waits until the server receives at least n batches
+func (self *seriesServer) waitBatches(t *testing.T, n int) [][]*Series {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		batches := self.received()
+		if len(batches) >= n {
+			return batches
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %v batches, received %v", n, len(batches))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func sampleAt(t uint64) *Sample {
+	return &Sample{T: net.Millis(t), V: net.Int64(t)}
+}
+
+func TestSeriesWriterMergesSeries(t *testing.T) {
+	client, server := newSeriesServer(t, 0)
+	writer := NewSeriesWriter(client, SeriesWriterConfig{BatchSize: 100, MaxLatency: time.Hour})
+	appended := []*Series{
+		{Entity: "e", Metric: "m", Tags: map[string]string{"a": "1", "b": "2"}, Data: []*Sample{sampleAt(1)}},
+		{Entity: "e", Metric: "m", Tags: map[string]string{"b": "2", "a": "1"}, Data: []*Sample{sampleAt(2)}},
+		{Entity: "e", Metric: "m", Tags: map[string]string{"a": "1", "b": "2"}, Type: Forecast, Data: []*Sample{sampleAt(3)}},
+		{Entity: "e", Metric: "m", Tags: map[string]string{"a": "1", "b": "2"}, Type: Forecast, ForecastName: "f", Data: []*Sample{sampleAt(4)}},
+		{Entity: "e", Metric: "m", Tags: map[string]string{"a": "1", "b": "2"}, Type: Forecast, Data: []*Sample{sampleAt(5)}},
+		{Entity: "e", Metric: "m", Tags: map[string]string{"a": "1", "b": "2"}, Type: Forecast, Meta: &ForecastMeta{Period: "1 day"}, Data: []*Sample{sampleAt(6)}},
+		{Entity: "e", Metric: "m", Tags: map[string]string{"a": "1", "b": "2"}, Type: Forecast, Meta: &ForecastMeta{Period: "1 day"}, Data: []*Sample{sampleAt(7)}},
+	}
+	for _, series := range appended {
+		if err := writer.Append(series); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writer.Close()
+
+	batches := server.received()
+	if len(batches) != 1 {
+		t.Fatalf("expected 1 batch, got %v", len(batches))
+	}
+	expected := []struct {
+		seriesType   SeriesType
+		forecastName string
+		meta         bool
+		times        []net.Millis
+	}{
+		{"", "", false, []net.Millis{1, 2}},
+		{Forecast, "", false, []net.Millis{3, 5}},
+		{Forecast, "f", false, []net.Millis{4}},
+		{Forecast, "", true, []net.Millis{6}},
+		{Forecast, "", true, []net.Millis{7}},
+	}
+	batch := batches[0]
+	if len(batch) != len(expected) {
+		t.Fatalf("expected %v series, got %v", len(expected), len(batch))
+	}
+	for i, series := range batch {
+		e := expected[i]
+		if series.Type != e.seriesType || series.ForecastName != e.forecastName || (series.Meta != nil) != e.meta {
+			t.Errorf("series %v: type %q, forecast name %q, meta %v", i, series.Type, series.ForecastName, series.Meta)
+		}
+		if series.Tags["a"] != "1" || series.Tags["b"] != "2" {
+			t.Errorf("series %v: tags %v", i, series.Tags)
+		}
+		if len(series.Data) != len(e.times) {
+			t.Errorf("series %v: expected %v samples, got %v", i, len(e.times), len(series.Data))
+			continue
+		}
+		for j, sample := range series.Data {
+			if sample.T != e.times[j] {
+				t.Errorf("series %v: sample %v at %v, expected %v", i, j, sample.T, e.times[j])
+			}
+		}
+	}
+}
+
+func TestSeriesWriterFlushesFullBatch(t *testing.T) {
+	client, server := newSeriesServer(t, 0)
+	writer := NewSeriesWriter(client, SeriesWriterConfig{BatchSize: 3, MaxLatency: time.Hour, Concurrency: 1})
+	defer writer.Close()
+	for i := uint64(1); i <= 7; i++ {
+		if err := writer.AppendSample("e", "m", nil, sampleAt(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	batches := server.waitBatches(t, 2)
+	for _, batch := range batches {
+		if countSamples(batch) != 3 {
+			t.Errorf("expected batches of 3 samples, got %v", countSamples(batch))
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := len(server.received()); n != 2 {
+		t.Errorf("the incomplete batch was sent before MaxLatency, %v batches", n)
+	}
+}
+
+func TestSeriesWriterFlushesAfterMaxLatency(t *testing.T) {
+	client, server := newSeriesServer(t, 0)
+	writer := NewSeriesWriter(client, SeriesWriterConfig{BatchSize: 1000, MaxLatency: 50 * time.Millisecond})
+	defer writer.Close()
+	start := time.Now()
+	if err := writer.AppendSample("e", "m", nil, sampleAt(1)); err != nil {
+		t.Fatal(err)
+	}
+	batches := server.waitBatches(t, 1)
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("batch sent after %v, before MaxLatency", elapsed)
+	}
+	if countSamples(batches[0]) != 1 {
+		t.Errorf("expected 1 sample, got %v", countSamples(batches[0]))
+	}
+}
+
+func TestSeriesWriterCloseDrainsQueue(t *testing.T) {
+	client, server := newSeriesServer(t, 10*time.Millisecond)
+	writer := NewSeriesWriter(client, SeriesWriterConfig{BatchSize: 10, MaxLatency: time.Hour, QueueSize: 1000})
+	const total = 500
+	for i := uint64(1); i <= total; i++ {
+		if err := writer.AppendSample("e", "m", nil, sampleAt(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	samples := 0
+	for _, batch := range server.received() {
+		samples += countSamples(batch)
+	}
+	if samples != total {
+		t.Errorf("expected %v samples after Close, received %v", total, samples)
+	}
+	for batchError := range writer.Errors() {
+		t.Errorf("unexpected error: %v", batchError)
+	}
+	if err := writer.AppendSample("e", "m", nil, sampleAt(1)); err != ErrWriterClosed {
+		t.Errorf("expected ErrWriterClosed, got %v", err)
+	}
+}
+
+func TestSeriesWriterReportsFailedBatches(t *testing.T) {
+	client, _ := newTestClient(t, testRetryPolicy(), func(n int32, w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	})
+	writer := NewSeriesWriter(client, SeriesWriterConfig{BatchSize: 2, MaxLatency: time.Hour})
+	for i := uint64(1); i <= 4; i++ {
+		writer.AppendSample("e", "m", nil, sampleAt(i))
+	}
+	writer.Close()
+
+	samples := 0
+	for batchError := range writer.Errors() {
+		var apiError *APIError
+		if !errors.As(batchError, &apiError) || apiError.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected 400 APIError, got %v", batchError.Err)
+		}
+		samples += countSamples(batchError.Series)
+	}
+	if samples != 4 {
+		t.Errorf("expected 4 failed samples, got %v", samples)
+	}
+}
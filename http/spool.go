@@ -0,0 +1,316 @@
+/*
+* Copyright 2015 Axibase Corporation or its affiliates. All Rights Reserved.
+*
+* Licensed under the Apache License, Version 2.0 (the "License").
+* You may not use this file except in compliance with the License.
+* A copy of the License is located at
+*
+* https://www.axibase.com/atsd/axibase-apache-2.0.pdf
+*
+* or in the "license" file accompanying this file. This file is distributed
+* on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+* express or implied. See the License for the specific language governing
+* permissions and limitations under the License.
+ */
+
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const (
+	spoolSuffix    = ".spool"
+	rejectedSuffix = ".rejected"
+)
+
+type SpoolConfig struct {
+	Dir            string        //directory for spooled batches, created if it does not exist
+	MaxSize        int64         //maximum total size of spooled batches in bytes, the oldest are dropped first, 0 is unlimited
+	MaxAge         time.Duration //batches older than MaxAge are dropped instead of replayed, 0 is unlimited
+	ReplayInterval time.Duration //how often delivery of spooled batches is attempted, 30 seconds by default
+}
+
+// Spool persists insert batches which could not be delivered because the server was unavailable
+// and replays them in their original order once it is back. Spooled batches survive restarts.
+type Spool struct {
+	config SpoolConfig
+
+	mutex    sync.Mutex
+	files    []string //pending batch files, oldest first
+	size     int64
+	sequence int64
+
+	replayMutex sync.Mutex
+	client      *Client
+	stop        chan struct{}
+	done        chan struct{}
+}
+
+type spooledBatch struct {
+	Path string          `json:"path"`
+	Body json.RawMessage `json:"body"`
+}
+
+func NewSpool(config SpoolConfig) (*Spool, error) {
+	if config.Dir == "" {
+		return nil, errors.New("http: spool directory is not set")
+	}
+	if config.ReplayInterval <= 0 {
+		config.ReplayInterval = 30 * time.Second
+	}
+	if err := os.MkdirAll(config.Dir, 0755); err != nil {
+		return nil, err
+	}
+	infos, err := ioutil.ReadDir(config.Dir)
+	if err != nil {
+		return nil, err
+	}
+	spool := &Spool{config: config}
+	for _, info := range infos {
+		if info.IsDir() || !strings.HasSuffix(info.Name(), spoolSuffix) {
+			continue
+		}
+		spool.files = append(spool.files, info.Name())
+		spool.size += info.Size()
+	}
+	sort.Strings(spool.files)
+	return spool, nil
+}
+
+// Pending returns the number of batches waiting for delivery
+func (self *Spool) Pending() int {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return len(self.files)
+}
+
+// start replays the spool through client every ReplayInterval until Close
+func (self *Spool) start(client *Client) {
+	self.Close()
+	self.replayMutex.Lock()
+	self.client = client
+	self.stop = make(chan struct{})
+	self.done = make(chan struct{})
+	stop, done := self.stop, self.done
+	self.replayMutex.Unlock()
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(self.config.ReplayInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if self.Pending() > 0 {
+					if err := self.Replay(context.Background()); err != nil {
+						glog.Warningf("spool: replay stopped: %v", err)
+					}
+				}
+			}
+		}
+	}()
+}
+
+// Close stops replaying, spooled batches stay on disk
+func (self *Spool) Close() error {
+	self.replayMutex.Lock()
+	stop, done := self.stop, self.done
+	self.stop, self.done = nil, nil
+	self.replayMutex.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+	return nil
+}
+
+// Replay sends the spooled batches in order. It stops at the first batch which cannot be delivered
+// because the server is unavailable; batches rejected by the server are renamed to *.rejected.
+func (self *Spool) Replay(ctx context.Context) error {
+	self.replayMutex.Lock()
+	defer self.replayMutex.Unlock()
+	if self.client == nil {
+		return errors.New("http: spool is not attached to a client")
+	}
+	for {
+		self.mutex.Lock()
+		if len(self.files) == 0 {
+			self.mutex.Unlock()
+			return nil
+		}
+		name := self.files[0]
+		self.mutex.Unlock()
+
+		if self.expired(name) {
+			glog.Warningf("spool: dropped expired batch %v", name)
+			self.remove(name, "")
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(self.config.Dir, name))
+		if err != nil {
+			glog.Errorf("spool: rejected unreadable batch %v: %v", name, err)
+			self.remove(name, rejectedSuffix)
+			continue
+		}
+		var batch spooledBatch
+		if err := json.Unmarshal(data, &batch); err != nil {
+			glog.Errorf("spool: rejected corrupted batch %v: %v", name, err)
+			self.remove(name, rejectedSuffix)
+			continue
+		}
+		_, err = self.client.request(ctx, "POST", batch.Path, batch.Body)
+		if err != nil {
+			if isUnavailable(err) {
+				return err
+			}
+			glog.Errorf("spool: batch %v rejected by the server: %v", name, err)
+			self.remove(name, rejectedSuffix)
+			continue
+		}
+		self.remove(name, "")
+	}
+}
+
+// store writes the batch to a new file after all pending batches
+func (self *Spool) store(apiUrl string, body []byte) error {
+	data, err := json.Marshal(&spooledBatch{Path: apiUrl, Body: body})
+	if err != nil {
+		return err
+	}
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.sequence++
+	name := fmt.Sprintf("%020d-%06d%v", time.Now().UnixNano(), self.sequence%1000000, spoolSuffix)
+	path := filepath.Join(self.config.Dir, name)
+	tmp := path + ".tmp"
+	if err := writeFileSync(tmp, data); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := syncDir(self.config.Dir); err != nil {
+		glog.Warningf("spool: %v", err)
+	}
+	self.files = append(self.files, name)
+	self.size += int64(len(data))
+
+	for self.config.MaxSize > 0 && self.size > self.config.MaxSize && len(self.files) > 1 {
+		oldest := self.files[0]
+		glog.Warningf("spool: dropped batch %v, spool exceeds %v bytes", oldest, self.config.MaxSize)
+		self.removeLocked(oldest, "")
+	}
+	return nil
+}
+
+// writeFileSync writes the file and flushes it to stable storage
+func writeFileSync(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// syncDir flushes the directory entries, so that a renamed file survives a power loss
+func syncDir(dir string) error {
+	file, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return file.Sync()
+}
+
+func (self *Spool) expired(name string) bool {
+	if self.config.MaxAge <= 0 {
+		return false
+	}
+	var created int64
+	if _, err := fmt.Sscanf(name, "%d-", &created); err != nil {
+		return false
+	}
+	return time.Since(time.Unix(0, created)) > self.config.MaxAge
+}
+
+func (self *Spool) remove(name, renameSuffix string) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.removeLocked(name, renameSuffix)
+}
+
+// removeLocked deletes the batch file, or renames it by appending renameSuffix if it is not empty
+func (self *Spool) removeLocked(name, renameSuffix string) {
+	path := filepath.Join(self.config.Dir, name)
+	if info, err := os.Stat(path); err == nil {
+		self.size -= info.Size()
+	}
+	var err error
+	if renameSuffix != "" {
+		err = os.Rename(path, path+renameSuffix)
+	} else {
+		err = os.Remove(path)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		glog.Errorf("spool: %v", err)
+	}
+	for i, file := range self.files {
+		if file == name {
+			self.files = append(self.files[:i], self.files[i+1:]...)
+			break
+		}
+	}
+}
+
+// isUnavailable reports whether err means the server could not be reached or is temporarily
+// unable to process requests, as opposed to rejecting the request itself
+func isUnavailable(err error) bool {
+	var requestError *RequestError
+	var encodeError *EncodeError
+	var decodeError *DecodeError
+	if errors.As(err, &requestError) || errors.As(err, &encodeError) || errors.As(err, &decodeError) {
+		return false
+	}
+	var apiError *APIError
+	if errors.As(err, &apiError) {
+		return apiError.IsRetryable()
+	}
+	if errors.Is(err, context.DeadlineExceeded) || isConnectionError(err) {
+		return true
+	}
+	// url.Error is a net.Error itself, the cause decides, e.g. an invalid certificate is not
+	var urlError *url.Error
+	if errors.As(err, &urlError) {
+		err = urlError.Err
+	}
+	var netError net.Error
+	return errors.As(err, &netError)
+}
@@ -0,0 +1,310 @@
+/*
+* Copyright 2015 Axibase Corporation or its affiliates. All Rights Reserved.
+*
+* Licensed under the Apache License, Version 2.0 (the "License").
+* You may not use this file except in compliance with the License.
+* A copy of the License is located at
+*
+* https://www.axibase.com/atsd/axibase-apache-2.0.pdf
+*
+* or in the "license" file accompanying this file. This file is distributed
+* on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+* express or implied. See the License for the specific language governing
+* permissions and limitations under the License.
+ */
+
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// spoolServer accepts series inserts while it is up and answers 503 while it is down,
+// entities listed in reject are answered with 400
+type spoolServer struct {
+	down     int32
+	mutex    sync.Mutex
+	entities []string
+	reject   map[string]bool
+}
+
+func newSpoolClient(t *testing.T, server *spoolServer, spool *Spool) *Client {
+	client, _ := newTestClient(t, testRetryPolicy(), func(n int32, w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&server.down) != 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var series []*Series
+		if err := json.NewDecoder(r.Body).Decode(&series); err != nil || len(series) != 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		server.mutex.Lock()
+		defer server.mutex.Unlock()
+		if server.reject[series[0].Entity] {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		server.entities = append(server.entities, series[0].Entity)
+	})
+	client.SetSpool(spool)
+	t.Cleanup(func() { spool.Close() })
+	return client
+}
+
+func (self *spoolServer) setDown(down bool) {
+	var value int32
+	if down {
+		value = 1
+	}
+	atomic.StoreInt32(&self.down, value)
+}
+
+func (self *spoolServer) received() []string {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return append([]string(nil), self.entities...)
+}
+
+func newTestSpool(t *testing.T, config SpoolConfig) *Spool {
+	if config.Dir == "" {
+		config.Dir = t.TempDir()
+	}
+	config.ReplayInterval = time.Hour
+	spool, err := NewSpool(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return spool
+}
+
+func insertEntities(t *testing.T, client *Client, entities ...string) {
+	for _, entity := range entities {
+		if err := client.Series.Insert([]*Series{{Entity: entity, Metric: "m"}}); err != nil {
+			t.Fatalf("insert %v: %v", entity, err)
+		}
+	}
+}
+
+func spoolFiles(t *testing.T, dir, pattern string) []string {
+	files, err := filepath.Glob(filepath.Join(dir, pattern))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func expectEntities(t *testing.T, received []string, expected ...string) {
+	t.Helper()
+	if fmt.Sprint(received) != fmt.Sprint(expected) {
+		t.Errorf("expected %v, received %v", expected, received)
+	}
+}
+
+func TestSpoolStoresAndReplaysInOrder(t *testing.T) {
+	server := &spoolServer{}
+	spool := newTestSpool(t, SpoolConfig{})
+	client := newSpoolClient(t, server, spool)
+
+	server.setDown(true)
+	insertEntities(t, client, "e1", "e2", "e3")
+	server.setDown(false)
+	insertEntities(t, client, "e4")
+	if pending := spool.Pending(); pending != 4 {
+		t.Fatalf("expected 4 pending batches, got %v", pending)
+	}
+	expectEntities(t, server.received())
+
+	if err := spool.Replay(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	expectEntities(t, server.received(), "e1", "e2", "e3", "e4")
+	if files := spoolFiles(t, spool.config.Dir, "*"); spool.Pending() != 0 || len(files) != 0 {
+		t.Errorf("expected an empty spool, got %v pending, files %v", spool.Pending(), files)
+	}
+
+	insertEntities(t, client, "e5")
+	expectEntities(t, server.received(), "e1", "e2", "e3", "e4", "e5")
+}
+
+func TestSpoolReplayStopsWhileUnavailable(t *testing.T) {
+	server := &spoolServer{}
+	spool := newTestSpool(t, SpoolConfig{})
+	client := newSpoolClient(t, server, spool)
+
+	server.setDown(true)
+	insertEntities(t, client, "e1", "e2")
+	var apiError *APIError
+	if err := spool.Replay(context.Background()); !errors.As(err, &apiError) || apiError.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 APIError, got %v", err)
+	}
+	if pending := spool.Pending(); pending != 2 {
+		t.Errorf("expected 2 pending batches, got %v", pending)
+	}
+}
+
+func TestSpoolReplaysAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	server := &spoolServer{}
+	server.setDown(true)
+	spool := newTestSpool(t, SpoolConfig{Dir: dir})
+	client := newSpoolClient(t, server, spool)
+	insertEntities(t, client, "e1", "e2", "e3")
+	spool.Close()
+	ioutil.WriteFile(filepath.Join(dir, "unrelated.txt"), []byte("text"), 0644)
+
+	restarted := newTestSpool(t, SpoolConfig{Dir: dir})
+	if pending := restarted.Pending(); pending != 3 {
+		t.Fatalf("expected 3 batches on disk, got %v", pending)
+	}
+	server.setDown(false)
+	newSpoolClient(t, server, restarted)
+	if err := restarted.Replay(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	expectEntities(t, server.received(), "e1", "e2", "e3")
+	if files := spoolFiles(t, dir, "*"+spoolSuffix); len(files) != 0 {
+		t.Errorf("expected replayed files to be removed, got %v", files)
+	}
+}
+
+func TestSpoolMaxSize(t *testing.T) {
+	body, _ := json.Marshal([]*Series{{Entity: "e0", Metric: "m"}})
+	batch, _ := json.Marshal(&spooledBatch{Path: seriesInsertPath, Body: body})
+	server := &spoolServer{}
+	spool := newTestSpool(t, SpoolConfig{MaxSize: int64(2*len(batch) + len(batch)/2)})
+	client := newSpoolClient(t, server, spool)
+
+	server.setDown(true)
+	insertEntities(t, client, "e1", "e2", "e3", "e4")
+	if pending := spool.Pending(); pending != 2 {
+		t.Errorf("expected 2 pending batches, got %v", pending)
+	}
+	if files := spoolFiles(t, spool.config.Dir, "*"+spoolSuffix); len(files) != 2 {
+		t.Errorf("expected 2 files, got %v", files)
+	}
+	server.setDown(false)
+	if err := spool.Replay(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	expectEntities(t, server.received(), "e3", "e4")
+}
+
+func TestSpoolMaxAge(t *testing.T) {
+	server := &spoolServer{}
+	spool := newTestSpool(t, SpoolConfig{MaxAge: 100 * time.Millisecond})
+	client := newSpoolClient(t, server, spool)
+
+	server.setDown(true)
+	insertEntities(t, client, "e1", "e2")
+	time.Sleep(200 * time.Millisecond)
+	insertEntities(t, client, "e3")
+	server.setDown(false)
+	if err := spool.Replay(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	expectEntities(t, server.received(), "e3")
+	if files := spoolFiles(t, spool.config.Dir, "*"); len(files) != 0 {
+		t.Errorf("expected expired files to be removed, got %v", files)
+	}
+}
+
+func TestSpoolRenamesRejectedBatches(t *testing.T) {
+	server := &spoolServer{reject: map[string]bool{"e2": true}}
+	spool := newTestSpool(t, SpoolConfig{})
+	client := newSpoolClient(t, server, spool)
+
+	server.setDown(true)
+	insertEntities(t, client, "e1", "e2", "e3")
+	spool.Close()
+	corrupted := fmt.Sprintf("%020d-%06d%v", time.Now().UnixNano(), 0, spoolSuffix)
+	ioutil.WriteFile(filepath.Join(spool.config.Dir, corrupted), []byte("{"), 0644)
+
+	restarted := newTestSpool(t, SpoolConfig{Dir: spool.config.Dir})
+	server.setDown(false)
+	newSpoolClient(t, server, restarted)
+	if err := restarted.Replay(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	expectEntities(t, server.received(), "e1", "e3")
+	if pending := restarted.Pending(); pending != 0 {
+		t.Errorf("expected no pending batches, got %v", pending)
+	}
+	rejected := spoolFiles(t, spool.config.Dir, "*"+spoolSuffix+rejectedSuffix)
+	if len(rejected) != 2 {
+		t.Fatalf("expected 2 rejected files, got %v", rejected)
+	}
+	found := false
+	for _, path := range rejected {
+		found = found || filepath.Base(path) == corrupted+rejectedSuffix
+	}
+	if !found {
+		t.Errorf("corrupted batch %v is not rejected: %v", corrupted, rejected)
+	}
+}
+
+func TestSpoolSkipsFailedAuthentication(t *testing.T) {
+	client, requests := newTestClient(t, testRetryPolicy(), func(n int32, w http.ResponseWriter, r *http.Request) {})
+	client.SetAuthenticator(AuthenticatorFunc(func(req *http.Request) error {
+		return &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	}))
+	spool := newTestSpool(t, SpoolConfig{})
+	client.SetSpool(spool)
+	defer spool.Close()
+
+	if err := client.Series.Insert([]*Series{{Entity: "e", Metric: "m"}}); err == nil {
+		t.Error("expected the authentication error")
+	}
+	if pending := spool.Pending(); pending != 0 {
+		t.Errorf("expected nothing spooled, got %v batches", pending)
+	}
+	if *requests != 0 {
+		t.Errorf("expected no requests, got %v", *requests)
+	}
+}
+
+func TestIsUnavailable(t *testing.T) {
+	refused := &url.Error{Op: "Post", URL: "http://localhost", Err: &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}}
+	tests := []struct {
+		err         error
+		unavailable bool
+	}{
+		{&APIError{StatusCode: http.StatusServiceUnavailable}, true},
+		{&APIError{StatusCode: http.StatusBadGateway}, true},
+		{&APIError{StatusCode: http.StatusBadRequest}, false},
+		{&APIError{StatusCode: http.StatusUnauthorized}, false},
+		{refused, true},
+		{syscall.ECONNRESET, true},
+		{syscall.EPIPE, true},
+		{io.EOF, true},
+		{&url.Error{Op: "Post", URL: "http://localhost", Err: io.ErrUnexpectedEOF}, true},
+		{&net.DNSError{Err: "no such host", Name: "atsd"}, true},
+		{context.DeadlineExceeded, true},
+		{context.Canceled, false},
+		{&url.Error{Op: "Post", URL: "http://localhost", Err: errors.New("x509: certificate signed by unknown authority")}, false},
+		{&DecodeError{Err: io.ErrUnexpectedEOF}, false},
+		{&RequestError{Method: "POST", Path: seriesInsertPath, Err: refused}, false},
+		{&EncodeError{Err: errors.New("unsupported value")}, false},
+		{errors.New("unknown"), false},
+	}
+	for _, test := range tests {
+		if unavailable := isUnavailable(test.err); unavailable != test.unavailable {
+			t.Errorf("isUnavailable(%v) = %v, expected %v", test.err, unavailable, test.unavailable)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+/*
+* Copyright 2015 Axibase Corporation or its affiliates. All Rights Reserved.
+*
+* Licensed under the Apache License, Version 2.0 (the "License").
+* You may not use this file except in compliance with the License.
+* A copy of the License is located at
+*
+* https://www.axibase.com/atsd/axibase-apache-2.0.pdf
+*
+* or in the "license" file accompanying this file. This file is distributed
+* on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+* express or implied. See the License for the specific language governing
+* permissions and limitations under the License.
+ */
+
+package http
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SqlQuery builds ATSD SQL queries. Names are quoted as identifiers and values as literals,
+// only expressions passed to Select, Where, GroupBy and OrderBy are used verbatim.
+//
+//	query, err := NewSqlQuery("cpu_busy").
+//		Select("entity", "datetime").
+//		SelectAggregate(AgAvg, "value").
+//		WhereEntity("nurswgvml007").
+//		WhereTag("cpu", "total").
+//		WhereTime(start, end).
+//		GroupBy("entity").
+//		GroupByPeriod(Period{Count: 5, Unit: Minute}).
+//		Build()
+type SqlQuery struct {
+	metric     string
+	columns    []string
+	conditions []string
+	groupBy    []string
+	with       []string
+	orderBy    []string
+	limit      *uint64
+	offset     *uint64
+	err        error
+}
+
+func NewSqlQuery(metric string) *SqlQuery {
+	return &SqlQuery{metric: metric}
+}
+
+func (self *SqlQuery) Metric() string {
+	return self.metric
+}
+
+// Select adds column expressions, e.g. "entity", "value", "tags.cpu"
+func (self *SqlQuery) Select(columns ...string) *SqlQuery {
+	self.columns = append(self.columns, columns...)
+	return self
+}
+
+// SelectAggregate adds an aggregate of the column, e.g. AgAvg -> AVG(value), AgPercentile95 -> PERCENTILE(95, value).
+// AgDetail and the threshold aggregations have no SQL function and are reported by Build.
+func (self *SqlQuery) SelectAggregate(aggregation AggregationType, column string) *SqlQuery {
+	if aggregation == AgDetail || strings.HasPrefix(string(aggregation), "THRESHOLD_") {
+		return self.fail(fmt.Errorf("http: aggregation %v is not supported in SQL", aggregation))
+	}
+	if percentile, ok := percentiles[aggregation]; ok {
+		return self.Select("PERCENTILE(" + percentile + ", " + column + ")")
+	}
+	if function, ok := sqlFunctions[aggregation]; ok {
+		return self.Select(function + "(" + column + ")")
+	}
+	return self.Select(string(aggregation) + "(" + column + ")")
+}
+
+// sqlFunctions maps aggregations whose SQL function is named differently
+var sqlFunctions = map[AggregationType]string{
+	AgStandardDeviation: "STDDEV",
+}
+
+var percentiles = map[AggregationType]string{
+	AgPercentile999: "99.9",
+	AgPercentile995: "99.5",
+	AgPercentile99:  "99",
+	AgPercentile95:  "95",
+	AgPercentile90:  "90",
+	AgPercentile75:  "75",
+	AgPercentile50:  "50",
+}
+
+// Where adds a condition, ? placeholders in it are replaced by quoted args
+func (self *SqlQuery) Where(condition string, args ...interface{}) *SqlQuery {
+	interpolated, err := Interpolate(condition, args...)
+	if err != nil {
+		return self.fail(err)
+	}
+	self.conditions = append(self.conditions, interpolated)
+	return self
+}
+
+func (self *SqlQuery) WhereEntity(entity string) *SqlQuery {
+	return self.Where("entity = ?", entity)
+}
+func (self *SqlQuery) WhereEntities(entities ...string) *SqlQuery {
+	if len(entities) == 0 {
+		return self.fail(errors.New("http: WhereEntities requires at least one entity"))
+	}
+	literals := make([]string, len(entities))
+	for i, entity := range entities {
+		literals[i] = QuoteString(entity)
+	}
+	self.conditions = append(self.conditions, "entity IN ("+strings.Join(literals, ", ")+")")
+	return self
+}
+
+// WhereTag adds tags."name" = 'value'
+func (self *SqlQuery) WhereTag(name, value string) *SqlQuery {
+	return self.Where(tagColumn(name)+" = ?", value)
+}
+
+// WhereTime limits datetime to [start, end), zero start or end leaves the interval unbounded
+func (self *SqlQuery) WhereTime(start, end time.Time) *SqlQuery {
+	if !start.IsZero() {
+		self.Where("datetime >= ?", start)
+	}
+	if !end.IsZero() {
+		self.Where("datetime < ?", end)
+	}
+	return self
+}
+
+// GroupBy adds grouping expressions, e.g. "entity", "tags"
+func (self *SqlQuery) GroupBy(columns ...string) *SqlQuery {
+	self.groupBy = append(self.groupBy, columns...)
+	return self
+}
+
+// GroupByPeriod adds PERIOD(count UNIT) to the grouping
+func (self *SqlQuery) GroupByPeriod(period Period) *SqlQuery {
+	return self.GroupBy("PERIOD(" + periodString(period) + ")")
+}
+
+// GroupByPeriodInterpolate adds PERIOD(count UNIT, interpolation) which fills empty periods
+func (self *SqlQuery) GroupByPeriodInterpolate(period Period, interpolation InterpolationType) *SqlQuery {
+	return self.GroupBy("PERIOD(" + periodString(period) + ", " + string(interpolation) + ")")
+}
+
+// WithInterpolate adds WITH INTERPOLATE(count UNIT, interpolation) which regularizes raw samples
+func (self *SqlQuery) WithInterpolate(period Period, interpolation InterpolationType) *SqlQuery {
+	self.with = append(self.with, "INTERPOLATE("+periodString(period)+", "+string(interpolation)+")")
+	return self
+}
+
+// WithRowNumber adds WITH ROW_NUMBER(partition ORDER BY order) <= max, e.g. the last max samples of each series
+func (self *SqlQuery) WithRowNumber(partitionBy []string, orderBy string, max uint64) *SqlQuery {
+	self.with = append(self.with, "ROW_NUMBER("+strings.Join(partitionBy, ", ")+" ORDER BY "+orderBy+") <= "+strconv.FormatUint(max, 10))
+	return self
+}
+
+// WithLastTime adds WITH time >= last_time - count*UNIT, i.e. samples within the period before the last sample of each series
+func (self *SqlQuery) WithLastTime(period Period) *SqlQuery {
+	self.with = append(self.with, "time >= last_time - "+strconv.FormatUint(uint64(period.Count), 10)+"*"+string(period.Unit))
+	return self
+}
+
+// OrderBy adds ordering expressions, e.g. "datetime DESC"
+func (self *SqlQuery) OrderBy(expressions ...string) *SqlQuery {
+	self.orderBy = append(self.orderBy, expressions...)
+	return self
+}
+
+func (self *SqlQuery) Limit(limit uint64) *SqlQuery {
+	self.limit = &limit
+	return self
+}
+
+// Offset skips rows, it requires Limit
+func (self *SqlQuery) Offset(offset uint64) *SqlQuery {
+	self.offset = &offset
+	return self
+}
+
+// fail records the first error, it is returned by Build
+func (self *SqlQuery) fail(err error) *SqlQuery {
+	if self.err == nil {
+		self.err = err
+	}
+	return self
+}
+
+// Build returns the query text, or the first error of a Select or Where call
+func (self *SqlQuery) Build() (string, error) {
+	if self.err != nil {
+		return "", self.err
+	}
+	if self.metric == "" {
+		return "", fmt.Errorf("http: SQL query has no metric")
+	}
+	if self.offset != nil && self.limit == nil {
+		return "", errors.New("http: SQL query has OFFSET without LIMIT")
+	}
+	query := bytes.NewBufferString("SELECT ")
+	if len(self.columns) == 0 {
+		query.WriteString("*")
+	} else {
+		query.WriteString(strings.Join(self.columns, ", "))
+	}
+	fmt.Fprintf(query, " FROM %v", QuoteIdentifier(self.metric))
+	if len(self.conditions) > 0 {
+		fmt.Fprintf(query, " WHERE %v", strings.Join(self.conditions, " AND "))
+	}
+	if len(self.with) > 0 {
+		fmt.Fprintf(query, " WITH %v", strings.Join(self.with, ", "))
+	}
+	if len(self.groupBy) > 0 {
+		fmt.Fprintf(query, " GROUP BY %v", strings.Join(self.groupBy, ", "))
+	}
+	if len(self.orderBy) > 0 {
+		fmt.Fprintf(query, " ORDER BY %v", strings.Join(self.orderBy, ", "))
+	}
+	if self.limit != nil {
+		fmt.Fprintf(query, " LIMIT %v", *self.limit)
+	}
+	if self.offset != nil {
+		fmt.Fprintf(query, " OFFSET %v", *self.offset)
+	}
+	return query.String(), nil
+}
+
+func (self *SqlQuery) String() string {
+	query, err := self.Build()
+	if err != nil {
+		return ""
+	}
+	return query
+}
+
+func tagColumn(name string) string {
+	return "tags." + QuoteIdentifier(name)
+}
+
+func periodString(period Period) string {
+	return strconv.FormatUint(uint64(period.Count), 10) + " " + string(period.Unit)
+}
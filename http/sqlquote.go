@@ -0,0 +1,156 @@
+/*
+* Copyright 2015 Axibase Corporation or its affiliates. All Rights Reserved.
+*
+* Licensed under the Apache License, Version 2.0 (the "License").
+* You may not use this file except in compliance with the License.
+* A copy of the License is located at
+*
+* https://www.axibase.com/atsd/axibase-apache-2.0.pdf
+*
+* or in the "license" file accompanying this file. This file is distributed
+* on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+* express or implied. See the License for the specific language governing
+* permissions and limitations under the License.
+ */
+
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/axibase/atsd-api-go/net"
+)
+
+// QuoteIdentifier quotes a metric, column or tag name, e.g. cpu_busy -> "cpu_busy"
+func QuoteIdentifier(name string) string {
+	return "\"" + strings.Replace(name, "\"", "\"\"", -1) + "\""
+}
+
+// QuoteString quotes a string literal, single quotes inside the value are doubled
+func QuoteString(value string) string {
+	return "'" + strings.Replace(value, "'", "''", -1) + "'"
+}
+
+// QuoteLiteral formats value as an SQL literal. Strings and []byte are quoted, times are
+// formatted as ISO dates in UTC, nil becomes NULL.
+func QuoteLiteral(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "NULL", nil
+	case string:
+		return QuoteString(v), nil
+	case []byte:
+		return QuoteString(string(v)), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int32:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float32:
+		return quoteFloat(float64(v))
+	case float64:
+		return quoteFloat(v)
+	case net.Int64, net.Int32, net.Int16, net.Uint64, net.Uint32, net.Uint16:
+		return v.(net.Number).String(), nil
+	case net.Number:
+		return quoteFloat(v.Float64())
+	case time.Time:
+		return QuoteString(v.UTC().Format("2006-01-02T15:04:05.000Z")), nil
+	}
+	return "", fmt.Errorf("http: unsupported SQL argument type %T", value)
+}
+
+func quoteFloat(value float64) (string, error) {
+	if math.IsNaN(value) {
+		return "NaN", nil
+	}
+	if math.IsInf(value, 0) {
+		return "", fmt.Errorf("http: cannot use %v as an SQL literal", value)
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64), nil
+}
+
+// scanPlaceholders calls placeholder for every ? outside of string literals, quoted identifiers
+// and comments, the rest of the query is passed to text
+func scanPlaceholders(query string, text func(string), placeholder func()) {
+	start := 0
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case c == '\'' || c == '"':
+			for i++; i < len(query); i++ {
+				if query[i] == c {
+					if i+1 < len(query) && query[i+1] == c {
+						i++
+						continue
+					}
+					break
+				}
+			}
+		case c == '-' && i+1 < len(query) && query[i+1] == '-':
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(query) && query[i+1] == '*':
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				i = len(query)
+			} else {
+				i += end + 3
+			}
+		case c == '?':
+			text(query[start:i])
+			placeholder()
+			start = i + 1
+		}
+	}
+	if start < len(query) {
+		text(query[start:])
+	}
+}
+
+// CountPlaceholders returns the number of ? placeholders in the query
+func CountPlaceholders(query string) int {
+	count := 0
+	scanPlaceholders(query, func(string) {}, func() { count++ })
+	return count
+}
+
+// Interpolate replaces ? placeholders with the literals of args, see QuoteLiteral
+func Interpolate(query string, args ...interface{}) (string, error) {
+	var result bytes.Buffer
+	var err error
+	n := 0
+	scanPlaceholders(query, func(text string) {
+		result.WriteString(text)
+	}, func() {
+		if n < len(args) {
+			literal, literalErr := QuoteLiteral(args[n])
+			if literalErr != nil && err == nil {
+				err = literalErr
+			}
+			result.WriteString(literal)
+		}
+		n++
+	})
+	if err != nil {
+		return "", err
+	}
+	if n != len(args) {
+		return "", fmt.Errorf("http: query has %v placeholders, %v arguments given", n, len(args))
+	}
+	return result.String(), nil
+}
@@ -0,0 +1,170 @@
+/*
+* Copyright 2015 Axibase Corporation or its affiliates. All Rights Reserved.
+*
+* Licensed under the Apache License, Version 2.0 (the "License").
+* You may not use this file except in compliance with the License.
+* A copy of the License is located at
+*
+* https://www.axibase.com/atsd/axibase-apache-2.0.pdf
+*
+* or in the "license" file accompanying this file. This file is distributed
+* on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+* express or implied. See the License for the specific language governing
+* permissions and limitations under the License.
+ */
+
+package http
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/axibase/atsd-api-go/net"
+)
+
+func TestQuoteString(t *testing.T) {
+	tests := map[string]string{
+		"":             "''",
+		"nurswgvml007": "'nurswgvml007'",
+		"it's":         "'it''s'",
+		"''":           "''''''",
+		"a' OR '1'='1": "'a'' OR ''1''=''1'",
+		`back\slash`:   `'back\slash'`,
+		`"double"`:     `'"double"'`,
+		"line\nbreak?": "'line\nbreak?'",
+	}
+	for value, expected := range tests {
+		if quoted := QuoteString(value); quoted != expected {
+			t.Errorf("QuoteString(%q) = %q, expected %q", value, quoted, expected)
+		}
+	}
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	tests := map[string]string{
+		"cpu_busy":         `"cpu_busy"`,
+		"disk used %":      `"disk used %"`,
+		`a"b`:              `"a""b"`,
+		`"; DROP TABLE x;`: `"""; DROP TABLE x;"`,
+		"it's":             `"it's"`,
+	}
+	for name, expected := range tests {
+		if quoted := QuoteIdentifier(name); quoted != expected {
+			t.Errorf("QuoteIdentifier(%q) = %q, expected %q", name, quoted, expected)
+		}
+	}
+}
+
+func TestQuoteLiteral(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		expected string
+	}{
+		{nil, "NULL"},
+		{"it's", "'it''s'"},
+		{[]byte("b'"), "'b'''"},
+		{true, "true"},
+		{int(-3), "-3"},
+		{int64(math.MaxInt64), "9223372036854775807"},
+		{uint64(math.MaxUint64), "18446744073709551615"},
+		{1.5, "1.5"},
+		{float32(0.25), "0.25"},
+		{math.NaN(), "NaN"},
+		{net.Int64(7), "7"},
+		{net.Float64(2.5), "2.5"},
+		{time.Date(2026, 10, 18, 10, 0, 0, 5e6, time.FixedZone("", 2*3600)), "'2026-10-18T08:00:00.005Z'"},
+	}
+	for _, test := range tests {
+		literal, err := QuoteLiteral(test.value)
+		if err != nil || literal != test.expected {
+			t.Errorf("QuoteLiteral(%#v) = %q, %v, expected %q", test.value, literal, err, test.expected)
+		}
+	}
+	for _, value := range []interface{}{math.Inf(1), struct{}{}, []string{"a"}} {
+		if literal, err := QuoteLiteral(value); err == nil {
+			t.Errorf("QuoteLiteral(%#v) = %q, expected an error", value, literal)
+		}
+	}
+}
+
+func TestScanPlaceholders(t *testing.T) {
+	tests := []struct {
+		query string
+		count int
+	}{
+		{"SELECT * FROM m WHERE entity = ?", 1},
+		{"SELECT * FROM m WHERE entity = '?'", 0},
+		{"SELECT * FROM m WHERE entity = 'it''s ?' AND value > ?", 1},
+		{`SELECT "col?" FROM "m""?" WHERE entity = ?`, 1},
+		{"SELECT * FROM m -- entity = ?\nWHERE value > ?", 1},
+		{"SELECT * FROM m /* ? */ WHERE value > ? /* ?", 1},
+		{"SELECT * FROM m WHERE entity = '' AND value > ?", 1},
+		{"SELECT * FROM m WHERE entity = 'unterminated ?", 0},
+		{"?,?,?", 3},
+	}
+	for _, test := range tests {
+		var text []string
+		placeholders := 0
+		scanPlaceholders(test.query, func(s string) {
+			text = append(text, s)
+		}, func() {
+			placeholders++
+			text = append(text, "?")
+		})
+		if placeholders != test.count || CountPlaceholders(test.query) != test.count {
+			t.Errorf("%q: expected %v placeholders, got %v", test.query, test.count, placeholders)
+		}
+		if joined := strings.Join(text, ""); joined != test.query {
+			t.Errorf("%q: text and placeholders join to %q", test.query, joined)
+		}
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	query, err := Interpolate("SELECT * FROM m WHERE entity = ? AND tags.note = '?' -- ?\nAND value > ?", "it's", 1.5)
+	expected := "SELECT * FROM m WHERE entity = 'it''s' AND tags.note = '?' -- ?\nAND value > 1.5"
+	if err != nil || query != expected {
+		t.Errorf("got %q, %v, expected %q", query, err, expected)
+	}
+	if _, err := Interpolate("SELECT * FROM m WHERE entity = ? AND value > ?", "e"); err == nil {
+		t.Error("expected an error for a missing argument")
+	}
+	if _, err := Interpolate("SELECT * FROM m WHERE entity = ?", "e", "extra"); err == nil {
+		t.Error("expected an error for an extra argument")
+	}
+}
+
+func TestSqlQueryBuild(t *testing.T) {
+	start := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	query, err := NewSqlQuery(`cpu "busy"`).
+		Select("entity").
+		SelectAggregate(AgStandardDeviation, "value").
+		SelectAggregate(AgPercentile95, "value").
+		WhereEntities("a", "it's").
+		WhereTag("cpu", "total").
+		WhereTime(start, time.Time{}).
+		GroupBy("entity").
+		Limit(10).
+		Offset(20).
+		Build()
+	expected := `SELECT entity, STDDEV(value), PERCENTILE(95, value) FROM "cpu ""busy""" WHERE entity IN ('a', 'it''s') AND tags."cpu" = 'total' AND datetime >= '2026-10-18T00:00:00.000Z' GROUP BY entity LIMIT 10 OFFSET 20`
+	if err != nil || query != expected {
+		t.Errorf("got %q, %v, expected %q", query, err, expected)
+	}
+
+	invalid := map[string]*SqlQuery{
+		"offset without limit": NewSqlQuery("m").Offset(10),
+		"no entities":          NewSqlQuery("m").WhereEntities(),
+		"detail":               NewSqlQuery("m").SelectAggregate(AgDetail, "value"),
+		"threshold":            NewSqlQuery("m").SelectAggregate(AgThresholdCount, "value"),
+		"arguments":            NewSqlQuery("m").Where("value > ?"),
+		"no metric":            NewSqlQuery(""),
+	}
+	for name, query := range invalid {
+		if text, err := query.Build(); err == nil {
+			t.Errorf("%v: expected an error, got %q", name, text)
+		}
+	}
+}
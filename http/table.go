@@ -1,6 +1,372 @@
 package http
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/axibase/atsd-api-go/net"
+)
+
 type Table struct {
-	Metadata interface{}     `json:"metadata"`
+	Metadata *TableMetadata  `json:"metadata"`
 	Data     [][]interface{} `json:"data"`
 }
+
+// TableMetadata is the JSON-LD description of an SQL result
+type TableMetadata struct {
+	Url     string
+	Title   string
+	Columns []*TableColumn
+}
+
+type TableColumn struct {
+	Index       int    `json:"columnIndex"`
+	Name        string `json:"name"`
+	Label       string `json:"titles"`
+	DataType    string `json:"datatype"`
+	Table       string `json:"table"`
+	PropertyUrl string `json:"propertyUrl"`
+}
+
+func (self *TableMetadata) UnmarshalJSON(data []byte) error {
+	var jsonMetadata struct {
+		Url         string `json:"url"`
+		Title       string `json:"dc:title"`
+		TableSchema struct {
+			Columns []*TableColumn `json:"columns"`
+		} `json:"tableSchema"`
+	}
+	if err := json.Unmarshal(data, &jsonMetadata); err != nil {
+		return err
+	}
+	self.Url = jsonMetadata.Url
+	self.Title = jsonMetadata.Title
+	self.Columns = jsonMetadata.TableSchema.Columns
+	return nil
+}
+
+// ColumnNames returns the labels of the columns, or their names if labels are absent
+func (self *TableMetadata) ColumnNames() []string {
+	names := make([]string, 0, len(self.Columns))
+	for _, column := range self.Columns {
+		if column.Label != "" {
+			names = append(names, column.Label)
+		} else {
+			names = append(names, column.Name)
+		}
+	}
+	return names
+}
+
+func (self *TableColumn) IsTime() bool {
+	switch strings.TrimPrefix(self.DataType, "xsd:") {
+	case "dateTimeStamp", "dateTime", "date", "timestamp":
+		return true
+	}
+	return false
+}
+func (self *TableColumn) IsNumber() bool {
+	switch strings.TrimPrefix(self.DataType, "xsd:") {
+	case "short", "integer", "int", "long", "bigint", "float", "double", "decimal", "number":
+		return true
+	}
+	return false
+}
+func (self *TableColumn) IsInteger() bool {
+	switch strings.TrimPrefix(self.DataType, "xsd:") {
+	case "short", "integer", "int", "long", "bigint":
+		return true
+	}
+	return false
+}
+
+// Convert turns a raw cell into a value of the column type: time.Time for timestamps, net.Number for numbers,
+// bool for booleans and string otherwise. Null cells are returned as nil.
+func (self *TableColumn) Convert(value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	switch {
+	case self.IsTime():
+		return cellTime(value)
+	case self.IsNumber():
+		number, err := cellNumber(value, self.IsInteger())
+		if err != nil || self.IsInteger() {
+			return number, err
+		}
+		if _, ok := number.(net.Int64); ok && strings.TrimPrefix(self.DataType, "xsd:") != "number" {
+			return net.Float64(number.Float64()), nil
+		}
+		return number, nil
+	case strings.TrimPrefix(self.DataType, "xsd:") == "boolean":
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			return strconv.ParseBool(v)
+		}
+		return nil, fmt.Errorf("cannot convert %#v to bool", value)
+	}
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	}
+	return fmt.Sprint(value), nil
+}
+
+func cellTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case string:
+		return time.Parse(time.RFC3339Nano, v)
+	case json.Number:
+		millis, err := v.Int64()
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(0, millis*1e6), nil
+	case time.Time:
+		return v, nil
+	}
+	return time.Time{}, fmt.Errorf("cannot convert %#v to time", value)
+}
+
+// cellNumber parses a numeric cell. Integers which do not fit into int64 are returned as float64
+// unless integer is set, malformed values are reported as errors.
+func cellNumber(value interface{}, integer bool) (net.Number, error) {
+	var text string
+	switch v := value.(type) {
+	case json.Number:
+		text = v.String()
+	case string:
+		text = v
+	case net.Number:
+		return v, nil
+	default:
+		return nil, fmt.Errorf("cannot convert %#v to number", value)
+	}
+	if !strings.ContainsAny(text, ".eEnN") {
+		n, err := strconv.ParseInt(text, 10, 64)
+		if err == nil {
+			return net.Int64(n), nil
+		}
+		if integer {
+			return nil, err
+		}
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return nil, err
+	}
+	if integer && f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+		return net.Int64(int64(f)), nil
+	}
+	return net.Float64(f), nil
+}
+
+// Row returns the cells of row i converted to the column types
+func (self *Table) Row(i int) ([]interface{}, error) {
+	if i < 0 || i >= len(self.Data) {
+		return nil, fmt.Errorf("http: row %v out of range", i)
+	}
+	return convertRow(self.Metadata, self.Data[i])
+}
+
+func convertRow(metadata *TableMetadata, row []interface{}) ([]interface{}, error) {
+	typed := make([]interface{}, len(row))
+	for j, value := range row {
+		if metadata == nil || j >= len(metadata.Columns) {
+			typed[j] = value
+			continue
+		}
+		converted, err := metadata.Columns[j].Convert(value)
+		if err != nil {
+			return nil, fmt.Errorf("http: column %v: %v", metadata.Columns[j].Name, err)
+		}
+		typed[j] = converted
+	}
+	return typed, nil
+}
+
+// ScanInto appends the rows to the slice of structs (or of pointers to structs) dest points to.
+// Columns are mapped to fields tagged `atsd:"column"` by label or name, untagged fields
+// are matched to columns case-insensitively by field name. Fields of type time.Time,
+// net.Number, string, bool, integers, floats and pointers to them are supported.
+//
+//	type Row struct {
+//		Entity string    `atsd:"entity"`
+//		Time   time.Time `atsd:"datetime"`
+//		Value  float64   `atsd:"value"`
+//	}
+//	var rows []Row
+//	err := table.ScanInto(&rows)
+func (self *Table) ScanInto(dest interface{}) error {
+	ptr := reflect.ValueOf(dest)
+	if ptr.Kind() != reflect.Ptr || ptr.Elem().Kind() != reflect.Slice {
+		return errors.New("http: ScanInto destination must be a pointer to a slice")
+	}
+	slice := ptr.Elem()
+	elemType := slice.Type().Elem()
+	structType := elemType
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return errors.New("http: ScanInto destination must be a slice of structs")
+	}
+	if self.Metadata == nil {
+		return errors.New("http: table has no metadata")
+	}
+	fields := mapColumns(self.Metadata, structType)
+
+	for i := range self.Data {
+		row, err := self.Row(i)
+		if err != nil {
+			return err
+		}
+		elem := reflect.New(structType).Elem()
+		for j, value := range row {
+			if j >= len(fields) || fields[j] < 0 {
+				continue
+			}
+			if err := setField(elem.Field(fields[j]), value); err != nil {
+				return fmt.Errorf("http: row %v, column %v: %v", i, self.Metadata.Columns[j].Name, err)
+			}
+		}
+		if elemType.Kind() == reflect.Ptr {
+			slice = reflect.Append(slice, elem.Addr())
+		} else {
+			slice = reflect.Append(slice, elem)
+		}
+	}
+	ptr.Elem().Set(slice)
+	return nil
+}
+
+// mapColumns returns the index of the struct field for every column, -1 if the column is not mapped
+func mapColumns(metadata *TableMetadata, structType reflect.Type) []int {
+	tagged := map[string]int{}
+	untagged := map[string]int{}
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		tag := field.Tag.Get("atsd")
+		if tag == "-" {
+			continue
+		}
+		if tag != "" {
+			tagged[tag] = i
+		} else {
+			untagged[strings.ToLower(field.Name)] = i
+		}
+	}
+	fields := make([]int, len(metadata.Columns))
+	for j, column := range metadata.Columns {
+		fields[j] = -1
+		if i, ok := tagged[column.Label]; ok {
+			fields[j] = i
+		} else if i, ok := tagged[column.Name]; ok {
+			fields[j] = i
+		} else if i, ok := untagged[strings.ToLower(column.Label)]; ok {
+			fields[j] = i
+		} else if i, ok := untagged[strings.ToLower(column.Name)]; ok {
+			fields[j] = i
+		}
+	}
+	return fields
+}
+
+var (
+	timeType   = reflect.TypeOf(time.Time{})
+	numberType = reflect.TypeOf((*net.Number)(nil)).Elem()
+)
+
+func setField(field reflect.Value, value interface{}) error {
+	if value == nil {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+	if field.Kind() == reflect.Ptr {
+		target := reflect.New(field.Type().Elem())
+		if err := setField(target.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(target)
+		return nil
+	}
+	switch {
+	case field.Type() == timeType:
+		t, err := cellTime(value)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	case field.Type() == numberType:
+		n, err := cellNumber(value, false)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(&n).Elem())
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.String:
+		switch v := value.(type) {
+		case string:
+			field.SetString(v)
+		case time.Time:
+			field.SetString(v.Format(time.RFC3339Nano))
+		default:
+			field.SetString(fmt.Sprint(v))
+		}
+		return nil
+	case reflect.Bool:
+		if b, ok := value.(bool); ok {
+			field.SetBool(b)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n, ok := cellInteger(value); ok && !field.OverflowInt(n) {
+			field.SetInt(n)
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n, ok := cellInteger(value); ok && n >= 0 && !field.OverflowUint(uint64(n)) {
+			field.SetUint(uint64(n))
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		if n, err := cellNumber(value, false); err == nil {
+			field.SetFloat(n.Float64())
+			return nil
+		}
+	case reflect.Interface:
+		if reflect.TypeOf(value).AssignableTo(field.Type()) {
+			field.Set(reflect.ValueOf(value))
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot assign %#v to field of type %v", value, field.Type())
+}
+
+// cellInteger returns the cell as int64 if it is a number without a fractional part
+func cellInteger(value interface{}) (int64, bool) {
+	n, err := cellNumber(value, true)
+	if err != nil {
+		return 0, false
+	}
+	if _, ok := n.(net.Int64); !ok {
+		return 0, false
+	}
+	return n.Int64(), true
+}
@@ -0,0 +1,82 @@
+/*
+* Copyright 2015 Axibase Corporation or its affiliates. All Rights Reserved.
+*
+* Licensed under the Apache License, Version 2.0 (the "License").
+* You may not use this file except in compliance with the License.
+* A copy of the License is located at
+*
+* https://www.axibase.com/atsd/axibase-apache-2.0.pdf
+*
+* or in the "license" file accompanying this file. This file is distributed
+* on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+* express or implied. See the License for the specific language governing
+* permissions and limitations under the License.
+ */
+
+package http
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testTable(t *testing.T, datatype string, data string) *Table {
+	var table Table
+	document := `{"metadata": {"tableSchema": {"columns": [
+		{"columnIndex": 1, "name": "datetime", "datatype": "xsd:dateTimeStamp"},
+		{"columnIndex": 2, "name": "value", "datatype": "` + datatype + `"}
+	]}}, "data": ` + data + `}`
+	dec := json.NewDecoder(strings.NewReader(document))
+	dec.UseNumber()
+	if err := dec.Decode(&table); err != nil {
+		t.Fatal(err)
+	}
+	return &table
+}
+
+func TestScanInto(t *testing.T) {
+	table := testTable(t, "xsd:long", `[["2026-10-18T08:00:00Z", 255], ["2026-10-18T08:00:15Z", null]]`)
+	var rows []struct {
+		Time  time.Time `atsd:"datetime"`
+		Value *uint8
+	}
+	if err := table.ScanInto(&rows); err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || !rows[0].Time.Equal(time.Date(2026, 10, 18, 8, 0, 0, 0, time.UTC)) ||
+		rows[0].Value == nil || *rows[0].Value != 255 || rows[1].Value != nil {
+		t.Errorf("unexpected rows %+v", rows)
+	}
+}
+
+func TestScanIntoRejectsValuesOutOfRange(t *testing.T) {
+	for _, value := range []string{"-1", "256", "1.5", `"abc"`, "99999999999999999999"} {
+		table := testTable(t, "xsd:long", `[["2026-10-18T08:00:00Z", `+value+`]]`)
+		var rows []struct {
+			Value uint8
+		}
+		if err := table.ScanInto(&rows); err == nil {
+			t.Errorf("%v: expected an error, got %+v", value, rows)
+		}
+	}
+	table := testTable(t, "xsd:long", `[["2026-10-18T08:00:00Z", 300]]`)
+	var rows []struct {
+		Value int8
+	}
+	if err := table.ScanInto(&rows); err == nil {
+		t.Errorf("expected an error for 300 in int8, got %+v", rows)
+	}
+}
+
+func TestConvertRejectsMalformedNumbers(t *testing.T) {
+	for _, datatype := range []string{"xsd:long", "xsd:double", "number"} {
+		column := &TableColumn{Name: "value", DataType: datatype}
+		for _, value := range []interface{}{"abc", "", json.Number("1x")} {
+			if converted, err := column.Convert(value); err == nil {
+				t.Errorf("%v %#v: expected an error, got %#v", datatype, value, converted)
+			}
+		}
+	}
+}
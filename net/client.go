@@ -0,0 +1,241 @@
+/*
+* Copyright 2015 Axibase Corporation or its affiliates. All Rights Reserved.
+*
+* Licensed under the Apache License, Version 2.0 (the "License").
+* You may not use this file except in compliance with the License.
+* A copy of the License is located at
+*
+* https://www.axibase.com/atsd/axibase-apache-2.0.pdf
+*
+* or in the "license" file accompanying this file. This file is distributed
+* on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+* express or implied. See the License for the specific language governing
+* permissions and limitations under the License.
+ */
+
+package net
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBufferSize        = 64 * 1024
+	defaultMaxBufferSize     = 16 * defaultBufferSize
+	defaultDialTimeout       = 5 * time.Second
+	defaultWriteTimeout      = 10 * time.Second
+	defaultReconnectInterval = 5 * time.Second
+)
+
+var (
+	ErrClientClosed = errors.New("net: client is closed")
+	ErrBufferFull   = errors.New("net: buffer is full, the server is unavailable")
+)
+
+// Command is implemented by SeriesCommand, PropertyCommand, MessageCommand and EntityTagCommand
+type Command interface {
+	String() string
+}
+
+// Client sends network commands to the ATSD TCP command port.
+// Commands are buffered and written when the buffer is full or Flush is called.
+// While the server is unavailable commands are kept in the buffer up to maxBufferSize bytes,
+// further commands are dropped and Send returns ErrBufferFull.
+type Client struct {
+	address           string
+	bufferSize        int
+	maxBufferSize     int
+	dialTimeout       time.Duration
+	writeTimeout      time.Duration
+	reconnectInterval time.Duration
+	dial              func(network, address string, timeout time.Duration) (net.Conn, error)
+
+	mutex   sync.Mutex
+	conn    net.Conn
+	buffer  bytes.Buffer
+	closed  bool
+	retryAt time.Time //Send does not reconnect before retryAt after a failed attempt
+	dropped uint64
+}
+
+// New connects to the ATSD command port, address is in "host:port" form (8081 by default)
+func New(address string) (*Client, error) {
+	client := &Client{
+		address:           address,
+		bufferSize:        defaultBufferSize,
+		maxBufferSize:     defaultMaxBufferSize,
+		dialTimeout:       defaultDialTimeout,
+		writeTimeout:      defaultWriteTimeout,
+		reconnectInterval: defaultReconnectInterval,
+		dial:              net.DialTimeout,
+	}
+	if err := client.connect(); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+func (self *Client) Address() string {
+	return self.address
+}
+
+func (self *Client) SetBufferSize(bufferSize int) *Client {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.bufferSize = bufferSize
+	return self
+}
+
+// SetMaxBufferSize limits the commands kept while the server is unavailable, 1 MiB by default
+func (self *Client) SetMaxBufferSize(maxBufferSize int) *Client {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.maxBufferSize = maxBufferSize
+	return self
+}
+func (self *Client) SetDialTimeout(dialTimeout time.Duration) *Client {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.dialTimeout = dialTimeout
+	return self
+}
+
+// SetWriteTimeout limits the time a single write may block on a stalled connection, 0 disables the limit
+func (self *Client) SetWriteTimeout(writeTimeout time.Duration) *Client {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.writeTimeout = writeTimeout
+	return self
+}
+
+// SetReconnectInterval sets the time Send waits after a failed connection attempt before it tries again
+func (self *Client) SetReconnectInterval(reconnectInterval time.Duration) *Client {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.reconnectInterval = reconnectInterval
+	return self
+}
+
+// Dropped returns the number of commands discarded because the buffer was full
+func (self *Client) Dropped() uint64 {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return self.dropped
+}
+
+func (self *Client) Send(command Command) error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if self.closed {
+		return ErrClientClosed
+	}
+	line := command.String()
+	if self.buffer.Len()+len(line) > self.maxBufferSize {
+		self.dropped++
+		return ErrBufferFull
+	}
+	self.buffer.WriteString(line)
+	if self.buffer.Len() >= self.bufferSize {
+		if self.conn == nil && time.Now().Before(self.retryAt) {
+			return nil
+		}
+		return self.flush()
+	}
+	return nil
+}
+
+func (self *Client) Flush() error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if self.closed {
+		return ErrClientClosed
+	}
+	return self.flush()
+}
+
+// Close flushes buffered commands and closes the connection
+func (self *Client) Close() error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if self.closed {
+		return nil
+	}
+	err := self.flush()
+	self.closed = true
+	if self.conn != nil {
+		if closeErr := self.conn.Close(); err == nil {
+			err = closeErr
+		}
+		self.conn = nil
+	}
+	return err
+}
+
+func (self *Client) connect() error {
+	conn, err := self.dial("tcp", self.address, self.dialTimeout)
+	if err != nil {
+		self.retryAt = time.Now().Add(self.reconnectInterval)
+		return err
+	}
+	self.conn = conn
+	return nil
+}
+
+func (self *Client) reconnect() error {
+	if self.conn != nil {
+		self.conn.Close()
+		self.conn = nil
+	}
+	return self.connect()
+}
+
+// flush writes the buffer. If the connection breaks, it reconnects once and writes the rest of the buffer
+// starting with the first line that was not written completely. Lines the kernel accepted before the
+// failure are not written again, though they may still be lost with the broken connection.
+func (self *Client) flush() error {
+	if self.buffer.Len() == 0 {
+		return nil
+	}
+	data := self.buffer.Bytes()
+	if self.conn == nil {
+		if err := self.connect(); err != nil {
+			return err
+		}
+	}
+	n, err := self.write(data)
+	if err != nil {
+		self.discardWritten(n)
+		if err := self.reconnect(); err != nil {
+			return err
+		}
+		if n, err := self.write(self.buffer.Bytes()); err != nil {
+			self.discardWritten(n)
+			self.conn.Close()
+			self.conn = nil
+			return err
+		}
+	}
+	self.buffer.Reset()
+	return nil
+}
+
+// discardWritten removes the lines completely contained in the first n bytes of the buffer
+func (self *Client) discardWritten(n int) {
+	self.buffer.Next(bytes.LastIndexByte(self.buffer.Bytes()[:n], '\n') + 1)
+}
+
+// write writes data to the connection within the write timeout
+func (self *Client) write(data []byte) (int, error) {
+	var deadline time.Time
+	if self.writeTimeout > 0 {
+		deadline = time.Now().Add(self.writeTimeout)
+	}
+	if err := self.conn.SetWriteDeadline(deadline); err != nil {
+		return 0, err
+	}
+	return self.conn.Write(data)
+}
@@ -0,0 +1,157 @@
+/*
+* Copyright 2015 Axibase Corporation or its affiliates. All Rights Reserved.
+*
+* Licensed under the Apache License, Version 2.0 (the "License").
+* You may not use this file except in compliance with the License.
+* A copy of the License is located at
+*
+* https://www.axibase.com/atsd/axibase-apache-2.0.pdf
+*
+* or in the "license" file accompanying this file. This file is distributed
+* on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+* express or implied. See the License for the specific language governing
+* permissions and limitations under the License.
+ */
+
+package net
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// brokenConn writes at most limit bytes and then drops the connection
+type brokenConn struct {
+	net.Conn
+	limit int
+}
+
+func (self *brokenConn) Write(data []byte) (int, error) {
+	if len(data) <= self.limit {
+		n, err := self.Conn.Write(data)
+		self.limit -= n
+		return n, err
+	}
+	n, _ := self.Conn.Write(data[:self.limit])
+	self.limit -= n
+	self.Conn.Close()
+	return n, errors.New("connection reset")
+}
+
+// listenTcp accepts connections and sends everything read from each of them to the returned channel
+func listenTcp(t *testing.T) (net.Listener, <-chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				data, _ := ioutil.ReadAll(conn)
+				conn.Close()
+				received <- string(data)
+			}()
+		}
+	}()
+	return listener, received
+}
+
+func receive(t *testing.T, received <-chan string) string {
+	select {
+	case data := <-received:
+		return data
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for data")
+		return ""
+	}
+}
+
+func TestClientResendsUnwrittenLinesAfterReconnect(t *testing.T) {
+	listener, received := listenTcp(t)
+	defer listener.Close()
+
+	client, err := New(listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the connection breaks in the middle of the fourth line
+	client.conn = &brokenConn{Conn: client.conn, limit: 25}
+
+	var lines []string
+	for i := 0; i < 10; i++ {
+		line := "line-" + strconv.Itoa(i) + "\n"
+		lines = append(lines, line)
+		if err := client.Send(lineCommand(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := client.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	first, second := receive(t, received), receive(t, received)
+	if len(first) > len(second) {
+		first, second = second, first
+	}
+	if expected := strings.Join(lines, "")[:25]; first != expected {
+		t.Errorf("first connection received %q, expected %q", first, expected)
+	}
+	if expected := strings.Join(lines[3:], ""); second != expected {
+		t.Errorf("second connection received %q, expected %q", second, expected)
+	}
+}
+
+func TestClientBufferFullWhileServerDown(t *testing.T) {
+	listener, _ := listenTcp(t)
+	defer listener.Close()
+
+	client, err := New(listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.conn.Close()
+	client.conn = nil
+	dials := 0
+	client.dial = func(network, address string, timeout time.Duration) (net.Conn, error) {
+		dials++
+		return nil, errors.New("connection refused")
+	}
+	client.SetBufferSize(10).SetMaxBufferSize(30).SetReconnectInterval(time.Hour)
+
+	var errs []error
+	for i := 0; i < 8; i++ {
+		errs = append(errs, client.Send(lineCommand("abcd\n")))
+	}
+	// the buffer reaches bufferSize with the second command, the connection attempt fails
+	// and the next attempt waits for the reconnect interval; commands over 30 bytes are dropped
+	if errs[1] == nil {
+		t.Error("expected the failed connection to be reported")
+	}
+	for i, err := range errs {
+		if (i < 6) != (err != ErrBufferFull) {
+			t.Errorf("command %v: unexpected error %v", i, err)
+		}
+	}
+	if dials != 1 {
+		t.Errorf("expected 1 connection attempt, got %v", dials)
+	}
+	if dropped := client.Dropped(); dropped != 2 {
+		t.Errorf("expected 2 dropped commands, got %v", dropped)
+	}
+	if err := client.Flush(); err == nil || dials != 2 {
+		t.Errorf("expected Flush to try to connect, got %v after %v attempts", err, dials)
+	}
+}
@@ -0,0 +1,143 @@
+/*
+* Copyright 2015 Axibase Corporation or its affiliates. All Rights Reserved.
+*
+* Licensed under the Apache License, Version 2.0 (the "License").
+* You may not use this file except in compliance with the License.
+* A copy of the License is located at
+*
+* https://www.axibase.com/atsd/axibase-apache-2.0.pdf
+*
+* or in the "license" file accompanying this file. This file is distributed
+* on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+* express or implied. See the License for the specific language governing
+* permissions and limitations under the License.
+ */
+
+package net
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+const defaultMtu = 1472
+
+type UdpCounters struct {
+	Sent      uint64 //commands written to the socket
+	Packets   uint64 //datagrams written to the socket
+	Dropped   uint64 //commands lost because the datagram could not be written
+	Oversized uint64 //commands discarded because they do not fit into a single datagram
+}
+
+// UdpClient sends network commands to the ATSD UDP command port without waiting for acknowledgement.
+// Commands are packed into datagrams of at most mtu bytes, a command is never split across datagrams.
+type UdpClient struct {
+	address string
+	mtu     int
+
+	mutex    sync.Mutex
+	conn     net.Conn
+	packet   bytes.Buffer
+	commands uint64
+	closed   bool
+
+	sent      uint64
+	packets   uint64
+	dropped   uint64
+	oversized uint64
+}
+
+// NewUdpClient opens a UDP socket to address in "host:port" form (8082 by default)
+func NewUdpClient(address string) (*UdpClient, error) {
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	return &UdpClient{address: address, mtu: defaultMtu, conn: conn}, nil
+}
+
+func (self *UdpClient) Address() string {
+	return self.address
+}
+func (self *UdpClient) Mtu() int {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return self.mtu
+}
+
+// SetMtu sets the maximum datagram payload size, pending commands are sent first
+func (self *UdpClient) SetMtu(mtu int) *UdpClient {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.writePacket()
+	self.mtu = mtu
+	return self
+}
+
+func (self *UdpClient) Counters() UdpCounters {
+	return UdpCounters{
+		Sent:      atomic.LoadUint64(&self.sent),
+		Packets:   atomic.LoadUint64(&self.packets),
+		Dropped:   atomic.LoadUint64(&self.dropped),
+		Oversized: atomic.LoadUint64(&self.oversized),
+	}
+}
+
+func (self *UdpClient) Send(command Command) error {
+	line := command.String()
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if self.closed {
+		return ErrClientClosed
+	}
+	if len(line) > self.mtu {
+		atomic.AddUint64(&self.oversized, 1)
+		return nil
+	}
+	if self.packet.Len()+len(line) > self.mtu {
+		self.writePacket()
+	}
+	self.packet.WriteString(line)
+	self.commands++
+	return nil
+}
+
+// Flush sends the pending datagram
+func (self *UdpClient) Flush() error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if self.closed {
+		return ErrClientClosed
+	}
+	self.writePacket()
+	return nil
+}
+
+// Close sends the pending datagram and closes the socket
+func (self *UdpClient) Close() error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if self.closed {
+		return nil
+	}
+	self.writePacket()
+	self.closed = true
+	return self.conn.Close()
+}
+
+func (self *UdpClient) writePacket() {
+	if self.packet.Len() == 0 {
+		return
+	}
+	if _, err := self.conn.Write(self.packet.Bytes()); err != nil {
+		atomic.AddUint64(&self.dropped, self.commands)
+	} else {
+		atomic.AddUint64(&self.sent, self.commands)
+		atomic.AddUint64(&self.packets, 1)
+	}
+	self.packet.Reset()
+	self.commands = 0
+}
@@ -0,0 +1,138 @@
+/*
+* Copyright 2015 Axibase Corporation or its affiliates. All Rights Reserved.
+*
+* Licensed under the Apache License, Version 2.0 (the "License").
+* You may not use this file except in compliance with the License.
+* A copy of the License is located at
+*
+* https://www.axibase.com/atsd/axibase-apache-2.0.pdf
+*
+* or in the "license" file accompanying this file. This file is distributed
+* on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+* express or implied. See the License for the specific language governing
+* permissions and limitations under the License.
+ */
+
+package net
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+type lineCommand string
+
+func (self lineCommand) String() string {
+	return string(self)
+}
+
+func listenUdp(t *testing.T) net.PacketConn {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return listener
+}
+
+// readDatagrams reads datagrams until none arrives within the timeout
+func readDatagrams(t *testing.T, listener net.PacketConn) []string {
+	var datagrams []string
+	buf := make([]byte, 65536)
+	for {
+		listener.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		n, _, err := listener.ReadFrom(buf)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				return datagrams
+			}
+			t.Fatal(err)
+		}
+		datagrams = append(datagrams, string(buf[:n]))
+	}
+}
+
+func TestUdpClientPacking(t *testing.T) {
+	listener := listenUdp(t)
+	defer listener.Close()
+
+	client, err := NewUdpClient(listener.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetMtu(100)
+
+	var sent []string
+	for i := 0; i < 10; i++ {
+		line := "series e:entity" + string(rune('a'+i)) + " m:metric=" + strings.Repeat("1", 8) + "\n"
+		sent = append(sent, line)
+		if err := client.Send(lineCommand(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := client.Send(lineCommand(strings.Repeat("x", 150) + "\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	datagrams := readDatagrams(t, listener)
+	var received []string
+	for _, datagram := range datagrams {
+		if len(datagram) > 100 {
+			t.Errorf("datagram of %v bytes exceeds mtu", len(datagram))
+		}
+		if !strings.HasSuffix(datagram, "\n") {
+			t.Errorf("datagram %q ends with a partial command", datagram)
+		}
+		for _, line := range strings.SplitAfter(datagram, "\n") {
+			if line != "" {
+				received = append(received, line)
+			}
+		}
+	}
+	if strings.Join(received, "") != strings.Join(sent, "") {
+		t.Errorf("received %q, expected %q", received, sent)
+	}
+	// 35 byte lines, 2 of them fit into 100 bytes
+	if len(datagrams) != 5 {
+		t.Errorf("expected 5 datagrams, got %v", len(datagrams))
+	}
+
+	counters := client.Counters()
+	expected := UdpCounters{Sent: 10, Packets: 5, Dropped: 0, Oversized: 1}
+	if counters != expected {
+		t.Errorf("counters %+v, expected %+v", counters, expected)
+	}
+}
+
+func TestUdpClientFlush(t *testing.T) {
+	listener := listenUdp(t)
+	defer listener.Close()
+
+	client, err := NewUdpClient(listener.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	client.Send(lineCommand("a\n"))
+	client.Send(lineCommand("b\n"))
+	if err := client.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	datagrams := readDatagrams(t, listener)
+	if len(datagrams) != 1 || datagrams[0] != "a\nb\n" {
+		t.Errorf("expected one datagram with both commands, got %q", datagrams)
+	}
+	if counters := client.Counters(); counters.Sent != 2 || counters.Packets != 1 {
+		t.Errorf("unexpected counters %+v", counters)
+	}
+
+	client.Close()
+	if err := client.Send(lineCommand("c\n")); err != ErrClientClosed {
+		t.Errorf("expected ErrClientClosed, got %v", err)
+	}
+}
@@ -0,0 +1,209 @@
+/*
+* Copyright 2015 Axibase Corporation or its affiliates. All Rights Reserved.
+*
+* Licensed under the Apache License, Version 2.0 (the "License").
+* You may not use this file except in compliance with the License.
+* A copy of the License is located at
+*
+* https://www.axibase.com/atsd/axibase-apache-2.0.pdf
+*
+* or in the "license" file accompanying this file. This file is distributed
+* on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+* express or implied. See the License for the specific language governing
+* permissions and limitations under the License.
+ */
+
+package sql
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testResult = `{
+	"metadata": {
+		"dc:title": "SQL Query Result",
+		"tableSchema": {
+			"columns": [
+				{"columnIndex": 1, "name": "datetime", "titles": "datetime", "datatype": "xsd:dateTimeStamp", "table": "cpu_busy"},
+				{"columnIndex": 2, "name": "value", "titles": "value", "datatype": "float", "table": "cpu_busy"},
+				{"columnIndex": 3, "name": "entity", "titles": "entity", "datatype": "string", "table": "cpu_busy"}
+			]
+		}
+	},
+	"data": [
+		["2026-10-18T08:00:00.000Z", 12.5, "nurswgvml007"],
+		["2026-10-18T08:00:15.000Z", 3, "nurswgvml010"]
+	]
+}`
+
+// testServer records the queries and credentials it receives and answers with testResult
+type testServer struct {
+	*httptest.Server
+
+	mutex    sync.Mutex
+	queries  []string
+	username string
+	password string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	server := &testServer{}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.mutex.Lock()
+		server.queries = append(server.queries, r.URL.Query().Get("q"))
+		server.username, server.password, _ = r.BasicAuth()
+		server.mutex.Unlock()
+		if r.URL.Path != "/api/sql" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(testResult))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (self *testServer) lastQuery() string {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if len(self.queries) == 0 {
+		return ""
+	}
+	return self.queries[len(self.queries)-1]
+}
+
+func (self *testServer) queryCount() int {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return len(self.queries)
+}
+
+func openTestDB(t *testing.T, server *testServer) *sql.DB {
+	dsn := strings.Replace(server.URL, "http://", "http://axibase:secret@", 1) + "?timeout=10s"
+	db, err := sql.Open("atsd", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestOpenMovesCredentialsToBasicAuth(t *testing.T) {
+	server := newTestServer(t)
+	db := openTestDB(t, server)
+
+	rows, err := db.Query(`SELECT datetime, value, entity FROM "cpu_busy"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+	if server.username != "axibase" || server.password != "secret" {
+		t.Errorf("expected basic auth axibase:secret, got %v:%v", server.username, server.password)
+	}
+}
+
+func TestQueryInterpolation(t *testing.T) {
+	server := newTestServer(t)
+	db := openTestDB(t, server)
+
+	rows, err := db.Query(`SELECT * FROM "cpu_busy" WHERE entity = ? AND tags.note = '?' AND value > ?`, "it's", 1.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+	expected := `SELECT * FROM "cpu_busy" WHERE entity = 'it''s' AND tags.note = '?' AND value > 1.5`
+	if query := server.lastQuery(); query != expected {
+		t.Errorf("sent %q, expected %q", query, expected)
+	}
+
+	for _, args := range [][]interface{}{{}, {"a", "b", "c"}} {
+		before := server.queryCount()
+		if _, err := db.Query(`SELECT * FROM "cpu_busy" WHERE entity = ? AND value > ?`, args...); err == nil {
+			t.Errorf("expected an error for %v arguments", len(args))
+		}
+		if server.queryCount() != before {
+			t.Errorf("query with %v arguments was sent", len(args))
+		}
+	}
+}
+
+func TestColumnTypes(t *testing.T) {
+	server := newTestServer(t)
+	db := openTestDB(t, server)
+
+	rows, err := db.Query(`SELECT datetime, value, entity FROM "cpu_busy"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		name         string
+		databaseType string
+		scanType     reflect.Type
+	}{
+		{"datetime", "DATETIMESTAMP", reflect.TypeOf(time.Time{})},
+		{"value", "FLOAT", reflect.TypeOf(float64(0))},
+		{"entity", "STRING", reflect.TypeOf("")},
+	}
+	if len(columnTypes) != len(expected) {
+		t.Fatalf("expected %v columns, got %v", len(expected), len(columnTypes))
+	}
+	for i, columnType := range columnTypes {
+		if columnType.Name() != expected[i].name ||
+			columnType.DatabaseTypeName() != expected[i].databaseType ||
+			columnType.ScanType() != expected[i].scanType {
+			t.Errorf("column %v: got %v %v %v, expected %+v", i, columnType.Name(), columnType.DatabaseTypeName(), columnType.ScanType(), expected[i])
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	server := newTestServer(t)
+	db := openTestDB(t, server)
+
+	rows, err := db.Query(`SELECT datetime, value, entity FROM "cpu_busy"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	type row struct {
+		datetime time.Time
+		value    float64
+		entity   string
+	}
+	expected := []row{
+		{time.Date(2026, 10, 18, 8, 0, 0, 0, time.UTC), 12.5, "nurswgvml007"},
+		{time.Date(2026, 10, 18, 8, 0, 15, 0, time.UTC), 3, "nurswgvml010"},
+	}
+	var scanned []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.datetime, &r.value, &r.entity); err != nil {
+			t.Fatal(err)
+		}
+		scanned = append(scanned, r)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(scanned) != len(expected) {
+		t.Fatalf("expected %v rows, got %v", len(expected), len(scanned))
+	}
+	for i := range expected {
+		if !scanned[i].datetime.Equal(expected[i].datetime) || scanned[i].value != expected[i].value || scanned[i].entity != expected[i].entity {
+			t.Errorf("row %v: got %+v, expected %+v", i, scanned[i], expected[i])
+		}
+	}
+}
@@ -0,0 +1,35 @@
+/*
+* Copyright 2015 Axibase Corporation or its affiliates. All Rights Reserved.
+*
+* Licensed under the Apache License, Version 2.0 (the "License").
+* You may not use this file except in compliance with the License.
+* A copy of the License is located at
+*
+* https://www.axibase.com/atsd/axibase-apache-2.0.pdf
+*
+* or in the "license" file accompanying this file. This file is distributed
+* on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+* express or implied. See the License for the specific language governing
+* permissions and limitations under the License.
+ */
+
+package sql
+
+import (
+	"database/sql/driver"
+
+	"github.com/axibase/atsd-api-go/http"
+)
+
+func countPlaceholders(query string) int {
+	return http.CountPlaceholders(query)
+}
+
+// interpolate replaces ? placeholders with quoted literals of args
+func interpolate(query string, args []driver.NamedValue) (string, error) {
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return http.Interpolate(query, values...)
+}
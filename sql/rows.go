@@ -0,0 +1,117 @@
+/*
+* Copyright 2015 Axibase Corporation or its affiliates. All Rights Reserved.
+*
+* Licensed under the Apache License, Version 2.0 (the "License").
+* You may not use this file except in compliance with the License.
+* A copy of the License is located at
+*
+* https://www.axibase.com/atsd/axibase-apache-2.0.pdf
+*
+* or in the "license" file accompanying this file. This file is distributed
+* on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+* express or implied. See the License for the specific language governing
+* permissions and limitations under the License.
+ */
+
+package sql
+
+import (
+	"database/sql/driver"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/axibase/atsd-api-go/http"
+	"github.com/axibase/atsd-api-go/net"
+)
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	int64Type   = reflect.TypeOf(int64(0))
+	float64Type = reflect.TypeOf(float64(0))
+	boolType    = reflect.TypeOf(false)
+	stringType  = reflect.TypeOf("")
+	anyType     = reflect.TypeOf((*interface{})(nil)).Elem()
+)
+
+// resultRows adapts the streaming http.Rows to driver.Rows
+type resultRows struct {
+	rows *http.Rows
+}
+
+func (self *resultRows) Columns() []string {
+	return self.rows.Columns()
+}
+func (self *resultRows) Close() error {
+	return self.rows.Close()
+}
+
+func (self *resultRows) Next(dest []driver.Value) error {
+	if !self.rows.Next() {
+		if err := self.rows.Err(); err != nil {
+			return err
+		}
+		return io.EOF
+	}
+	values, err := self.rows.TypedValues()
+	if err != nil {
+		return err
+	}
+	for i := range dest {
+		if i >= len(values) {
+			dest[i] = nil
+			continue
+		}
+		dest[i] = driverValue(values[i])
+	}
+	return nil
+}
+
+// driverValue converts a typed cell to one of the types allowed by driver.Value
+func driverValue(value interface{}) driver.Value {
+	switch v := value.(type) {
+	case net.Int64:
+		return int64(v)
+	case net.Number:
+		return v.Float64()
+	}
+	return value
+}
+
+func (self *resultRows) column(index int) *http.TableColumn {
+	metadata := self.rows.Metadata()
+	if metadata == nil || index >= len(metadata.Columns) {
+		return nil
+	}
+	return metadata.Columns[index]
+}
+
+func (self *resultRows) ColumnTypeDatabaseTypeName(index int) string {
+	column := self.column(index)
+	if column == nil {
+		return ""
+	}
+	return strings.ToUpper(strings.TrimPrefix(column.DataType, "xsd:"))
+}
+
+func (self *resultRows) ColumnTypeScanType(index int) reflect.Type {
+	column := self.column(index)
+	switch {
+	case column == nil:
+		return anyType
+	case column.IsTime():
+		return timeType
+	case column.IsInteger():
+		return int64Type
+	case column.IsNumber():
+		return float64Type
+	case strings.TrimPrefix(column.DataType, "xsd:") == "boolean":
+		return boolType
+	}
+	return stringType
+}
+
+func (self *resultRows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	return true, true
+}